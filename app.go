@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
-	"gopkg.in/yaml.v2"
+	"github.com/jinycoo/jinygo/secret"
 )
 
 const (
@@ -30,12 +30,14 @@ func (jiny *Jinygo) getModConfigFile(name string) string {
 	return file
 }
 
-func (jiny *Jinygo) initApp() {
+func (jiny *Jinygo) initApp() (err error) {
 	configFile := fmt.Sprintf("%s.%s", ConfigFileName, ConfigFileType)
 	cfgFile := filepath.Join(jiny.configPath, configFile)
 	if _, err := os.Stat(cfgFile); err == nil {
 		buf, _ := ioutil.ReadFile(cfgFile)
-		yaml.Unmarshal(buf, &cfg)
+		if err = secret.Unmarshal(buf, &cfg); err != nil {
+			err = fmt.Errorf("%s解析失败：%v", cfgFile, err)
+		}
 		if cfg.WebPort == 0 {
 			cfg.WebPort = Port
 		}
@@ -49,4 +51,5 @@ func (jiny *Jinygo) initApp() {
 		}
 	}
 	jiny.config = cfg
+	return err
 }
\ No newline at end of file
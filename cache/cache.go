@@ -1,9 +1,9 @@
 package cache
 
 import (
+	"fmt"
 	"io/ioutil"
-	"gopkg.in/yaml.v2"
-	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
 )
 
 const (
@@ -18,14 +18,14 @@ type CheConfig struct {
 	Mongo  *mongoConfig  `yaml:"mongo"`
 }
 
-func Init(cfgFile string) {
+func Init(cfgFile string) error {
 	buf, err := ioutil.ReadFile(cfgFile)
 	if err != nil {
-		log.Warn(cfgFile + "文件读取失败")
+		return fmt.Errorf("%s文件读取失败：%v", cfgFile, err)
 	}
-	err = yaml.Unmarshal(buf, &cacheCfg)
+	err = secret.Unmarshal(buf, &cacheCfg)
 	if err != nil {
-		log.Warn(cfgFile + "解析失败")
+		return fmt.Errorf("%s解析失败：%v", cfgFile, err)
 	}
 	redisCfg = cacheCfg.Redis
 	mongoCfg = cacheCfg.Mongo
@@ -35,6 +35,7 @@ func Init(cfgFile string) {
 	if mongoCfg != nil {
 		mongoConn("")
 	}
+	return nil
 }
 
 func InitCache(cfg *CheConfig) {
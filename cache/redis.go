@@ -2,8 +2,10 @@ package cache
 
 import (
 	"net"
+	"context"
 	"github.com/go-redis/redis"
 	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
 )
 
 var (
@@ -25,9 +27,67 @@ type RedisConfig struct {
 	Sentinel   []string      `yaml:"sentinel"`
 }
 
+// WithContext 返回绑定 ctx 的客户端，ctx 取消或超时后新的命令立即返回 ctx.Err()
+func WithContext(ctx context.Context) *redis.Client {
+	if RCache == nil {
+		return nil
+	}
+	c := RCache.WithContext(ctx)
+	c.WrapProcess(func(process func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			if err := ctx.Err(); err != nil {
+				return ctxClient(err).Process(cmd)
+			}
+			return process(cmd)
+		}
+	})
+	c.WrapProcessPipeline(func(process func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			if err := ctx.Err(); err != nil {
+				pipe := ctxClient(err).Pipeline()
+				for _, cmd := range cmds {
+					pipe.Process(cmd)
+				}
+				_, err = pipe.Exec()
+				return err
+			}
+			return process(cmds)
+		}
+	})
+	return c
+}
+
+// ctxClients 不建立连接，所有命令均以对应的 ctx 错误失败，用于设置命令的错误结果
+var ctxClients = map[error]*redis.Client{
+	context.Canceled:         newCtxClient(context.Canceled),
+	context.DeadlineExceeded: newCtxClient(context.DeadlineExceeded),
+}
+
+type ctxLimiter struct {
+	err error
+}
+
+func (l ctxLimiter) Allow() error {
+	return l.err
+}
+
+func (l ctxLimiter) ReportResult(result error) {}
+
+func ctxClient(err error) *redis.Client {
+	if c, ok := ctxClients[err]; ok {
+		return c
+	}
+	return ctxClients[context.Canceled]
+}
+
+func newCtxClient(err error) *redis.Client {
+	return redis.NewClient(&redis.Options{IdleTimeout: -1}).SetLimiter(ctxLimiter{err: err})
+}
+
 func redisConn(cluster string) {
 	if redisCfg.Master != nil {
 		master := redisCfg.Master
+		secret.Register(master.Password)
 		switch cluster {
 		case "", "standalone":
 			RCache = redis.NewClient(&redis.Options{
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"os"
+	"fmt"
+	"flag"
+	"sort"
+	"strconv"
+	"strings"
+	"github.com/jinycoo/jinygo/db"
+	"github.com/jinycoo/jinygo/gen"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/migrations"
+)
+
+const usage = `jinygo 命令行工具
+
+用法：
+  jinygo gen model [参数]             根据数据库表结构生成模型及 DAO
+  jinygo migrate up [参数]            执行全部未执行的迁移
+  jinygo migrate down [N] [参数]      回滚最近 N 个迁移，默认为 1
+  jinygo migrate to V [参数]          迁移至版本 V
+  jinygo migrate status [参数]        查看迁移状态
+
+`
+
+func main() {
+	// 命令行工具仅输出到标准错误，不写日志文件
+	logCfg := log.DevConfig()
+	logCfg.Level = "info"
+	logCfg.OutPuts = []string{log.Stderr}
+	log.New(logCfg)
+
+	var err error
+	switch {
+	case len(os.Args) >= 3 && os.Args[1] == "gen" && os.Args[2] == "model":
+		err = genModel(os.Args[3:])
+	case len(os.Args) >= 3 && os.Args[1] == "migrate":
+		err = migrate(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func genModel(args []string) error {
+	var (
+		opt    gen.ModelOption
+		config string
+		tables string
+	)
+	fs := flag.NewFlagSet("jinygo gen model", flag.ExitOnError)
+	fs.StringVar(&config, "config", "conf/database.yml", "数据库配置文件")
+	fs.StringVar(&opt.DbName, "db", "", "数据组名称")
+	fs.StringVar(&tables, "tables", "", "表名，以逗号分隔，留空生成全部表")
+	fs.StringVar(&opt.Prefix, "prefix", "", "生成结构体名时去除的表前缀")
+	fs.StringVar(&opt.Output, "out", "models", "输出目录")
+	fs.StringVar(&opt.Package, "pkg", "", "包名，默认为输出目录名")
+	fs.StringVar(&opt.Templates, "tpl", "", "模板目录，其中的 model.tpl、dao.tpl 覆盖默认模板")
+	fs.BoolVar(&opt.Dao, "dao", true, "是否生成 DAO")
+	fs.StringVar(&opt.Json, "json", gen.JsonSnake, "json 标签风格 snake 或 camel")
+	fs.Parse(args)
+	if opt.DbName == "" {
+		fs.Usage()
+		return fmt.Errorf("缺少参数 -db")
+	}
+	if tables != "" {
+		for _, t := range strings.Split(tables, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				opt.Tables = append(opt.Tables, t)
+			}
+		}
+	}
+	if err := db.Init(config); err != nil {
+		return err
+	}
+	defer db.Close()
+	files, err := gen.Models(opt)
+	for _, f := range files {
+		fmt.Println(f)
+	}
+	return err
+}
+
+func migrate(args []string) error {
+	var (
+		config string
+		dbName string
+		dir    string
+		dryRun bool
+	)
+	fs := flag.NewFlagSet("jinygo migrate", flag.ExitOnError)
+	fs.StringVar(&config, "config", "conf/database.yml", "数据库配置文件")
+	fs.StringVar(&dbName, "db", "", "数据组名称，up、status 留空时处理全部数据组")
+	fs.StringVar(&dir, "dir", "migrations", "迁移文件目录")
+	fs.BoolVar(&dryRun, "dry-run", false, "仅输出待执行的 SQL")
+	cmd, arg, err := migrateArgs(fs, args)
+	if err != nil {
+		fs.Usage()
+		return err
+	}
+	if dbName == "" && (cmd == "down" || cmd == "to") {
+		fs.Usage()
+		return fmt.Errorf("缺少参数 -db")
+	}
+
+	if err := db.Init(config); err != nil {
+		return err
+	}
+	defer db.Close()
+	names := []string{dbName}
+	if dbName == "" {
+		names = names[:0]
+		for name := range db.DataGroup {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		m, err := migrations.New(name, dir)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		m.DryRun = dryRun
+		switch cmd {
+		case "up":
+			err = m.Up()
+		case "down":
+			err = m.Down(int(arg))
+		case "to":
+			err = m.To(arg)
+		case "status":
+			err = printStatus(name, m)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// migrateArgs 解析子命令及其参数，参数与子命令可以任意顺序出现
+func migrateArgs(fs *flag.FlagSet, args []string) (cmd string, arg int64, err error) {
+	var pos []string
+	for {
+		fs.Parse(args)
+		if args = fs.Args(); len(args) == 0 {
+			break
+		}
+		pos, args = append(pos, args[0]), args[1:]
+	}
+	if len(pos) == 0 {
+		return "", 0, fmt.Errorf("缺少子命令")
+	}
+	cmd = pos[0]
+	switch cmd {
+	case "up", "status":
+		if len(pos) == 1 {
+			return cmd, 0, nil
+		}
+	case "down":
+		if len(pos) == 1 {
+			return cmd, 1, nil
+		}
+		if len(pos) == 2 {
+			if arg, err = strconv.ParseInt(pos[1], 10, 64); err == nil && arg > 0 {
+				return cmd, arg, nil
+			}
+			return "", 0, fmt.Errorf("回滚数量无效：%s", pos[1])
+		}
+	case "to":
+		if len(pos) == 2 {
+			if arg, err = strconv.ParseInt(pos[1], 10, 64); err == nil && arg >= 0 {
+				return cmd, arg, nil
+			}
+			return "", 0, fmt.Errorf("版本号无效：%s", pos[1])
+		}
+	default:
+		return "", 0, fmt.Errorf("未知子命令：%s", cmd)
+	}
+	return "", 0, fmt.Errorf("参数错误：%s", strings.Join(pos, " "))
+}
+
+func printStatus(name string, m *migrations.Migrator) error {
+	list, err := m.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Println(name)
+	for _, st := range list {
+		state := "pending"
+		if st.Applied {
+			state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if st.Modified {
+			state += " (modified)"
+		}
+		if st.Missing {
+			state += " (missing)"
+		}
+		fmt.Printf("  %d\t%s\t%s\n", st.Version, st.Name, state)
+	}
+	return nil
+}
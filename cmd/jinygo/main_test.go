@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestMigrateArgs(t *testing.T) {
+	cases := []struct {
+		args   []string
+		cmd    string
+		arg    int64
+		dryRun bool
+		err    bool
+	}{
+		{args: []string{"up"}, cmd: "up"},
+		{args: []string{"-dry-run", "up", "-db", "jiny_db"}, cmd: "up", dryRun: true},
+		{args: []string{"down"}, cmd: "down", arg: 1},
+		{args: []string{"down", "3", "-dry-run"}, cmd: "down", arg: 3, dryRun: true},
+		{args: []string{"to", "0"}, cmd: "to", arg: 0},
+		{args: []string{"status"}, cmd: "status"},
+		{args: []string{}, err: true},
+		{args: []string{"down", "0"}, err: true},
+		{args: []string{"to"}, err: true},
+		{args: []string{"up", "1"}, err: true},
+		{args: []string{"redo"}, err: true},
+	}
+	for _, c := range cases {
+		var dryRun bool
+		fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+		fs.String("db", "", "")
+		fs.BoolVar(&dryRun, "dry-run", false, "")
+		cmd, arg, err := migrateArgs(fs, c.args)
+		if c.err {
+			if err == nil {
+				t.Errorf("%v: expected error", c.args)
+			}
+			continue
+		}
+		if err != nil || cmd != c.cmd || arg != c.arg || dryRun != c.dryRun {
+			t.Errorf("%v: got %s %d %v %v", c.args, cmd, arg, dryRun, err)
+		}
+	}
+}
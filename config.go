@@ -2,9 +2,12 @@ package jinygo
 
 import (
 	"os"
+	"time"
 	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/web"
 	"github.com/jinycoo/jinygo/utils"
 	"github.com/jinycoo/jinygo/constants"
+	"github.com/jinycoo/jinygo/migrations"
 )
 
 var (
@@ -20,6 +23,10 @@ type Config struct {
 	WebPort     int                     `yaml:"port"`
 	Logger      *log.JLogConfig         `yaml:"log"`
 	Components  map[string]string       `yaml:"components"`
+	Static      []*web.StaticConfig     `yaml:"static"`
+	Timeout     time.Duration           `yaml:"timeout"`
+	Format      string                  `yaml:"format"`
+	Migrate     *migrations.Config      `yaml:"migrate"`
 }
 
 func init() {
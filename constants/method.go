@@ -13,10 +13,14 @@ const (
 
 	RunMode      = "RUN_MODE"
 	RunModeDebug = "debug"
+	SecretKey    = "JINYGO_SECRET_KEY"
 
 	ConfigFileDB = "db"
 	ConfigFileCache = "cache"
 	ConfigFileParams = "params"
 	ConfigFileMQ = "mqueue"
+	ConfigFileStorage = "storage"
+	ConfigFileHttpClient = "httpclient"
+	ConfigFileRbac = "rbac"
 	Separator = "/"
 )
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const (
+	AdapterMySQL    = "mysql"
+	AdapterPostgres = "postgres"
+	AdapterSQLite   = "sqlite3"
+
+	// SQLiteMemory sqlite3 的 addr 设置为 :memory: 时使用共享缓存的内存数据库
+	SQLiteMemory = ":memory:"
+)
+
+// adapter 数据库适配器，driver 为 database/sql 注册的驱动名
+type adapter struct {
+	driver string
+	port   int
+	params map[string]string
+	dsn    func(e *engineConfig, dbname string, params []string) string
+}
+
+var adapters = map[string]*adapter{
+	AdapterMySQL: {
+		driver: "mysql",
+		port:   3306,
+		params: map[string]string{"charset": "utf8mb4"},
+		dsn:    mysqlDsn,
+	},
+	AdapterPostgres: {
+		driver: "postgres",
+		port:   5432,
+		params: map[string]string{"sslmode": "disable"},
+		dsn:    postgresDsn,
+	},
+	AdapterSQLite: {
+		driver: "sqlite3",
+		params: map[string]string{"_busy_timeout": "5000"},
+		dsn:    sqliteDsn,
+	},
+}
+
+func getAdapter(name string) (*adapter, error) {
+	if name == "" {
+		name = AdapterMySQL
+	}
+	if a, ok := adapters[name]; ok {
+		return a, nil
+	}
+	return nil, fmt.Errorf("db: unknown adapter %q, supported adapters are mysql, postgres, sqlite3", name)
+}
+
+// buildDsn 未配置 dsn 时按适配器生成，params 以适配器默认参数为基础
+func (a *adapter) buildDsn(e *engineConfig, dbname string) string {
+	if e.Dsn != "" {
+		return e.Dsn
+	}
+	if e.Port == 0 {
+		e.Port = a.port
+	}
+	merged := make(map[string]string, len(a.params) + len(e.Params))
+	for k, v := range a.params {
+		merged[k] = v
+	}
+	for k, v := range e.Params {
+		merged[k] = v
+	}
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		params = append(params, k + "=" + merged[k])
+	}
+	return a.dsn(e, dbname, params)
+}
+
+// mysqlDsn user:pass@tcp(host:port)/dbname?params
+func mysqlDsn(e *engineConfig, dbname string, params []string) string {
+	var addr string
+	switch e.Protocol {
+	case "", "tcp":
+		if e.Host == "" {
+			e.Host = "127.0.0.1"
+		}
+		addr = fmt.Sprintf("tcp(%s:%d)", e.Host, e.Port)
+	default:
+		addr = fmt.Sprintf("%s(%s)", e.Protocol, e.Addr)
+	}
+	var dsnParams = ""
+	if len(params) > 0 {
+		dsnParams = "?" + strings.Join(params, "&")
+	}
+	return fmt.Sprintf("%s:%s@%s/%s%s", e.Username, e.Password, addr, dbname, dsnParams)
+}
+
+// postgresDsn host=... port=... user=... password=... dbname=... params，protocol 为 unix 时 addr 为 socket 目录
+func postgresDsn(e *engineConfig, dbname string, params []string) string {
+	host := e.Host
+	if e.Protocol == "unix" {
+		host = e.Addr
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	fields := []string{
+		"host=" + pgQuote(host),
+		fmt.Sprintf("port=%d", e.Port),
+		"dbname=" + pgQuote(dbname),
+	}
+	if e.Username != "" {
+		fields = append(fields, "user=" + pgQuote(e.Username))
+	}
+	if e.Password != "" {
+		fields = append(fields, "password=" + pgQuote(e.Password))
+	}
+	for _, p := range params {
+		kv := strings.SplitN(p, "=", 2)
+		fields = append(fields, kv[0] + "=" + pgQuote(kv[1]))
+	}
+	return strings.Join(fields, " ")
+}
+
+// sqliteDsn addr 为数据库文件路径，默认 <dbname>.db；addr 为 :memory: 时使用以 dbname 命名的共享内存数据库
+func sqliteDsn(e *engineConfig, dbname string, params []string) string {
+	file := e.Addr
+	if file == SQLiteMemory {
+		file = dbname
+		params = append(params, "mode=memory", "cache=shared")
+	} else if file == "" {
+		file = dbname + ".db"
+	}
+	if len(params) > 0 {
+		return "file:" + file + "?" + strings.Join(params, "&")
+	}
+	return "file:" + file
+}
+
+func pgQuote(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"errors"
+)
+
+const appSecretTTL = 5 * time.Minute
+
+// AppSecretStore 从数据表读取合作方 app key/secret，实现 web.SecretStore，查询结果缓存 5 分钟
+type AppSecretStore struct {
+	sync.RWMutex
+	dbName       string
+	table        string
+	KeyColumn    string
+	SecretColumn string
+	secrets      map[string]appSecret
+}
+
+type appSecret struct {
+	secret  string
+	expired time.Time
+}
+
+func AppSecrets(dbName, table string) *AppSecretStore {
+	return &AppSecretStore{
+		dbName:       dbName,
+		table:        table,
+		KeyColumn:    "app_key",
+		SecretColumn: "app_secret",
+		secrets:      make(map[string]appSecret),
+	}
+}
+
+func (s *AppSecretStore) Secret(appKey string) (string, error) {
+	s.RLock()
+	cached, ok := s.secrets[appKey]
+	s.RUnlock()
+	if ok && time.Now().Before(cached.expired) {
+		return cached.secret, nil
+	}
+	engine := Use(s.dbName)
+	if engine == nil {
+		return "", errors.New(s.dbName + " - Database does not exist.")
+	}
+	rows, err := engine.QueryString(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", s.SecretColumn, s.table, s.KeyColumn), appKey)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", errors.New("app key not found")
+	}
+	secret := rows[0][s.SecretColumn]
+	s.Lock()
+	s.secrets[appKey] = appSecret{secret: secret, expired: time.Now().Add(appSecretTTL)}
+	s.Unlock()
+	return secret, nil
+}
@@ -3,29 +3,42 @@ package db
 import (
 	"fmt"
 	"time"
+	"errors"
+	"context"
 	"strings"
 	"io/ioutil"
-	"gopkg.in/yaml.v2"
+	"github.com/go-xorm/core"
 	"github.com/go-xorm/xorm"
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
 )
 
 var (
 	dbCfg  *dbConfig
-	DataGroup map[string]*xorm.EngineGroup
+	DataGroup map[string]*Group
 )
 
 type dbGroupConfig struct {
-	OpenConns       int          `yaml:"openConns"`
-	IdleConns       int          `yaml:"idleConns"`
+	Adapter         string        `yaml:"adapter"`
+	OpenConns       int           `yaml:"openConns"`
+	IdleConns       int           `yaml:"idleConns"`
 	ConnMaxLifetime int           `yaml:"maxLifetime"`
-	Master          *engineConfig `yaml:"master`
-	Slaves          []string      `yaml:"slaves"`
+	Policy          string        `yaml:"policy"`
+	MaxLag          int           `yaml:"maxLag"`
+	LagCheck        int           `yaml:"lagCheck"`
+	ShowSql         bool          `yaml:"showSql"`
+	SlowQuery       int           `yaml:"slowQuery"`
+	Redact          []string      `yaml:"redact"`
+	Master          *engineConfig `yaml:"master"`
+	Slaves          []*slaveConfig `yaml:"slaves"`
 }
 type dbConfig struct {
-	Adapter string                      `yaml:"adapter"`
-	Db      map[string]*dbGroupConfig   `yaml:"db"`
+	Adapter        string                    `yaml:"adapter"`
+	ConnectTimeout int                       `yaml:"connectTimeout"`
+	Degraded       bool                      `yaml:"degraded"`
+	HealthCheck    int                       `yaml:"healthCheck"`
+	Tenant         *tenantConfig             `yaml:"tenant"`
+	Db             map[string]*dbGroupConfig `yaml:"db"`
 }
 type engineConfig struct {
 	Dsn      string  `yaml:"dsn"`
@@ -38,90 +51,203 @@ type engineConfig struct {
 	Params   map[string]string  `yaml:"params"`
 }
 
-func initDataGroup() map[string]*xorm.EngineGroup {
-	var groups = make(map[string]*xorm.EngineGroup)
+// slaveConfig 从库配置，未设置的账号、协议、端口及参数继承主库配置，Weight 默认为 1
+type slaveConfig struct {
+	engineConfig `yaml:",inline"`
+	Weight       int `yaml:"weight"`
+}
+
+// UnmarshalYAML 兼容以 dsn 字符串配置的从库
+func (s *slaveConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var dsn string
+	if err := unmarshal(&dsn); err == nil {
+		s.Dsn = dsn
+		return nil
+	}
+	type plain slaveConfig
+	return unmarshal((*plain)(s))
+}
+
+// inherit 以主库配置补全从库配置
+func (s *slaveConfig) inherit(master *engineConfig) *engineConfig {
+	e := s.engineConfig
+	if e.Dsn != "" {
+		return &e
+	}
+	if e.Username == "" {
+		e.Username = master.Username
+		if e.Password == "" {
+			e.Password = master.Password
+		}
+	}
+	if e.Protocol == "" {
+		e.Protocol = master.Protocol
+	}
+	if e.Port == 0 {
+		e.Port = master.Port
+	}
+	params := make(map[string]string, len(master.Params) + len(e.Params))
+	for k, v := range master.Params {
+		params[k] = v
+	}
+	for k, v := range e.Params {
+		params[k] = v
+	}
+	e.Params = params
+	return &e
+}
+
+func initDataGroup() (map[string]*Group, error) {
+	var groups = make(map[string]*Group)
 	if dbCfg == nil {
-		log.Error("db config setting error")
+		return groups, errors.New("db config setting error")
 	}
+	deadline := time.Now().Add(time.Duration(dbCfg.ConnectTimeout) * time.Second)
+	var errs []string
 	for g, e := range dbCfg.Db {
-		dataSourceSlice := make([]string, 0)
-		dataSourceSlice = append(dataSourceSlice, e.Master.parseDns(g))
-		for _, sn := range dbCfg.Db[g].Slaves {
-			dataSourceSlice = append(dataSourceSlice, sn)
+		group, err := openGroup(g, g, e)
+		if err == nil {
+			err = group.connect(deadline)
+			if err != nil && dbCfg.Degraded {
+				log.Error(g + " - 主库连接失败，以降级模式启动：" + err.Error())
+				err = nil
+			}
 		}
-		if len(dataSourceSlice) > 0 {
-			group, err := xorm.NewEngineGroup(dbCfg.Adapter, dataSourceSlice)
-			if err != nil {
-				log.Warn("创建数据组链接错误：" + err.Error())
+		if err != nil {
+			if group != nil {
+				group.Close()
 			}
-			group.SetMaxOpenConns(dbCfg.Db[g].OpenConns)
-			group.SetMaxIdleConns(dbCfg.Db[g].IdleConns)
-			group.SetConnMaxLifetime(time.Duration(dbCfg.Db[g].ConnMaxLifetime) * time.Second)
-			groups[g] = group
-			log.Info(fmt.Sprintf("%s EngineGroup Opened", g))
+			errs = append(errs, g + ": " + err.Error())
+			continue
+		}
+		if interval := healthInterval(e); interval > 0 {
+			group.monitor(interval)
 		}
+		groups[g] = group
+		log.Info(fmt.Sprintf("%s EngineGroup Opened", g))
 	}
-	return groups
+	if len(errs) > 0 {
+		return groups, errors.New("db: " + strings.Join(errs, "; "))
+	}
+	return groups, nil
 }
 
-func Use(dbName string) *xorm.Engine {
-	if DataGroup == nil {
-		DataGroup = initDataGroup()
+// healthInterval 数据组的健康检查间隔，未配置时开启 maxLag 的数据组使用 DefaultHealthCheck
+func healthInterval(e *dbGroupConfig) time.Duration {
+	interval := e.LagCheck
+	if interval <= 0 {
+		interval = dbCfg.HealthCheck
+	}
+	if interval <= 0 && e.MaxLag > 0 && len(e.Slaves) > 0 {
+		interval = DefaultHealthCheck
+	}
+	return time.Duration(interval) * time.Second
+}
+
+// openGroup 打开数据组，dbname 为连接的数据库名，租户数据组的 g 与 dbname 不同
+func openGroup(g, dbname string, e *dbGroupConfig) (*Group, error) {
+	if e.Adapter == "" {
+		e.Adapter = dbCfg.Adapter
+	}
+	a, err := getAdapter(e.Adapter)
+	if err != nil {
+		return nil, err
+	}
+	if e.Master == nil {
+		return nil, errors.New("database master must be setting")
+	}
+	policy, err := groupPolicy(e.Policy, e.Slaves)
+	if err != nil {
+		return nil, err
+	}
+	secret.Register(e.Master.Password)
+	for _, slave := range e.Slaves {
+		secret.Register(slave.Password)
 	}
+	dataSourceSlice := make([]string, 0)
+	dataSourceSlice = append(dataSourceSlice, a.buildDsn(e.Master, dbname))
+	for _, slave := range e.Slaves {
+		dataSourceSlice = append(dataSourceSlice, a.buildDsn(slave.inherit(e.Master), dbname))
+	}
+	driverName, err := logDriverName(a.driver)
+	if err != nil {
+		return nil, err
+	}
+	sl := &sqlLogger{group: g, showSql: e.ShowSql, slow: time.Duration(e.SlowQuery) * time.Millisecond, redact: append(append([]string{}, DefaultRedact...), e.Redact...)}
+	logId, logDsn := registerSqlLogger(sl)
+	for i, dsn := range dataSourceSlice {
+		dataSourceSlice[i] = logDsn(dsn)
+	}
+	eg, err := xorm.NewEngineGroup(driverName, dataSourceSlice, policy)
+	if err != nil {
+		unregisterSqlLogger(logId)
+		return nil, err
+	}
+	eg.SetLogger(&xormLogger{group: g, level: core.LOG_WARNING})
+	eg.ShowSQL(false)
+	eg.SetMaxOpenConns(e.OpenConns)
+	eg.SetMaxIdleConns(e.IdleConns)
+	eg.SetConnMaxLifetime(time.Duration(e.ConnMaxLifetime) * time.Second)
+	group := newGroup(g, eg, a)
+	group.logId = logId
+	eg.SetPolicy(&healthPolicy{policy: policy, group: group})
+	group.maxLag = time.Duration(e.MaxLag) * time.Second
+	return group, nil
+}
+
+// UseContext 返回绑定 ctx 的读写分离会话，ctx 取消或超时后查询随之取消
+// 通过 ReadYourWrites 开启且已经 Group.Write 写入时使用主库，ctx 来自 Tx 的 Session.Context 时返回该事务会话
+func UseContext(ctx context.Context, dbName string) *xorm.Session {
+	if state := txFrom(ctx, dbName); state != nil {
+		return state.session
+	}
+	if g, ok := DataGroup[dbName]; ok {
+		return g.Context(ctx)
+	}
+	log.Error(dbName + " - Database does not exist.")
+	return nil
+}
+
+// Use 返回数据组，读操作使用从库，写操作使用主库，需强制使用主库时使用 Master
+func Use(dbName string) *Group {
 	if g, ok := DataGroup[dbName]; ok {
-		return g.Engine
+		return g
 	} else {
 		log.Error(dbName + " - Database does not exist.")
 	}
 	return nil
 }
 
-func Init(dbCfgFile string) {
+// Register 直接注册数据组，用于测试或自行创建的 EngineGroup
+func Register(dbName string, group *xorm.EngineGroup) {
+	if DataGroup == nil {
+		DataGroup = make(map[string]*Group)
+	}
+	a, _ := getAdapter(strings.TrimPrefix(group.DriverName(), logDriverPrefix))
+	DataGroup[dbName] = newGroup(dbName, group, a)
+}
+
+// Init 读取配置并连接各数据组，主库在 connectTimeout 内按指数退避重试
+// 开启 degraded 时主库连接失败的数据组仍被注册，由健康检查在恢复后记录日志
+func Init(dbCfgFile string) error {
 	buf, err := ioutil.ReadFile(dbCfgFile)
 	if err != nil {
-		log.Warn(dbCfgFile + "文件读取失败")
+		return fmt.Errorf("%s文件读取失败：%v", dbCfgFile, err)
 	}
-	err = yaml.Unmarshal(buf, &dbCfg)
+	err = secret.Unmarshal(buf, &dbCfg)
 	if err != nil {
-		log.Warn(dbCfgFile + "解析失败")
+		return fmt.Errorf("%s解析失败：%v", dbCfgFile, err)
 	}
-	DataGroup = initDataGroup()
-}
-func (e *engineConfig) parseDns(dbname string) string {
-	if e.Dsn == "" {
-		var addr string
-		switch e.Protocol {
-		case "", "tcp":
-			if e.Host == "" {
-				e.Host = "127.0.0.1"
-			}
-			if e.Port == 0 {
-				e.Port = 3306
-			}
-			addr = fmt.Sprintf("tcp(%s:%d)", e.Host, e.Port)
-		default:
-			addr = fmt.Sprintf("%s(%s)", e.Protocol, e.Addr)
-		}
-		var params= make([]string, 0)
-		for k, v := range e.Params {
-			params = append(params, fmt.Sprintf("%s=%v", k, v))
-		}
-		var dsnParams = ""
-		if len(params) > 0 {
-			dsnParams = "?" + strings.Join(params, "&")
-		}
-		e.Dsn = fmt.Sprintf("%s:%s@%s/%s%s",
-			e.Username,
-			e.Password,
-			addr,
-			dbname,
-			dsnParams,
-		)
-	}
-	return e.Dsn
+	DataGroup, err = initDataGroup()
+	if err == nil && dbCfg.Tenant != nil {
+		Tenants, err = newTenantRegistry(dbCfg.Tenant)
+	}
+	return err
 }
-
 func Close() {
+	if Tenants != nil {
+		Tenants.Close()
+	}
 	for n,db := range DataGroup {
 		db.Close()
 		log.Info(fmt.Sprintf("%s EngineGroup Closed", n))
@@ -0,0 +1,387 @@
+package db
+
+import (
+	"sync"
+	"time"
+	"context"
+	"github.com/go-xorm/xorm"
+)
+
+// Group 数据组，自动提交的读操作经负载策略路由至健康的从库，写操作及事务使用主库
+// 从库全部不可用或复制延迟超过 maxLag 时读操作回退至主库
+// 返回会话的方法及 Get、Find、Iterate、Sum 等读方法均经 Context 路由，仅用于写入的 Incr、Decr、SetExpr、NoAutoTime 及 Insert、Update、Delete、Exec 使用主库
+type Group struct {
+	*xorm.EngineGroup
+	name    string
+	adapter *adapter
+	maxLag  time.Duration
+	logId   string
+
+	mu         sync.RWMutex
+	down       map[*xorm.Engine]bool
+	masterDown bool
+	stop       chan struct{}
+}
+
+type (
+	rywKey struct{}
+
+	// writeMarks 记录请求内已写入的数据组
+	writeMarks struct {
+		sync.Mutex
+		dbs map[string]bool
+	}
+
+	// healthPolicy 跳过不健康从库的负载策略
+	healthPolicy struct {
+		policy xorm.GroupPolicy
+		group  *Group
+	}
+)
+
+func newGroup(name string, eg *xorm.EngineGroup, a *adapter) *Group {
+	return &Group{EngineGroup: eg, name: name, adapter: a, down: make(map[*xorm.Engine]bool)}
+}
+
+// ReadYourWrites 返回开启读己所写的上下文，经 Write(ctx) 写入后，同一上下文的 UseContext 读操作使用主库
+func ReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(rywKey{}).(*writeMarks); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, rywKey{}, &writeMarks{dbs: make(map[string]bool)})
+}
+
+func written(ctx context.Context, dbName string) bool {
+	if marks, ok := ctx.Value(rywKey{}).(*writeMarks); ok {
+		marks.Lock()
+		defer marks.Unlock()
+		return marks.dbs[dbName]
+	}
+	return false
+}
+
+// Master 返回数据组的主库
+func Master(dbName string) *xorm.Engine {
+	if g := Use(dbName); g != nil {
+		return g.Engine
+	}
+	return nil
+}
+
+// NewSession 返回读写分离的会话
+func (g *Group) NewSession() *xorm.Session {
+	if !g.replicaAvailable() {
+		return g.Engine.NewSession()
+	}
+	return g.EngineGroup.NewSession()
+}
+
+// Context 返回绑定 ctx 的读写分离会话，ctx 内已写入该数据组时使用主库
+func (g *Group) Context(ctx context.Context) *xorm.Session {
+	if state := txFrom(ctx, g.name); state != nil {
+		return state.session
+	}
+	if written(ctx, g.name) || !g.replicaAvailable() {
+		return g.Engine.Context(ctx)
+	}
+	return g.EngineGroup.Context(ctx)
+}
+
+// Write 返回绑定 ctx 的主库会话，并在开启读己所写的上下文中标记该数据组已写入
+func (g *Group) Write(ctx context.Context) *xorm.Session {
+	if marks, ok := ctx.Value(rywKey{}).(*writeMarks); ok {
+		marks.Lock()
+		marks.dbs[g.name] = true
+		marks.Unlock()
+	}
+	if state := txFrom(ctx, g.name); state != nil {
+		return state.session
+	}
+	return g.Engine.Context(ctx)
+}
+
+func (g *Group) Table(tableNameOrBean interface{}) *xorm.Session {
+	return g.Context(context.Background()).Table(tableNameOrBean)
+}
+
+func (g *Group) Where(query interface{}, args ...interface{}) *xorm.Session {
+	return g.Context(context.Background()).Where(query, args...)
+}
+
+func (g *Group) ID(id interface{}) *xorm.Session {
+	return g.Context(context.Background()).ID(id)
+}
+
+func (g *Group) In(column string, args ...interface{}) *xorm.Session {
+	return g.Context(context.Background()).In(column, args...)
+}
+
+func (g *Group) SQL(query interface{}, args ...interface{}) *xorm.Session {
+	return g.Context(context.Background()).SQL(query, args...)
+}
+
+func (g *Group) Select(str string) *xorm.Session {
+	return g.Context(context.Background()).Select(str)
+}
+
+func (g *Group) Cols(columns ...string) *xorm.Session {
+	return g.Context(context.Background()).Cols(columns...)
+}
+
+func (g *Group) OrderBy(order string) *xorm.Session {
+	return g.Context(context.Background()).OrderBy(order)
+}
+
+func (g *Group) Desc(colNames ...string) *xorm.Session {
+	return g.Context(context.Background()).Desc(colNames...)
+}
+
+func (g *Group) Asc(colNames ...string) *xorm.Session {
+	return g.Context(context.Background()).Asc(colNames...)
+}
+
+func (g *Group) Limit(limit int, start ...int) *xorm.Session {
+	return g.Context(context.Background()).Limit(limit, start...)
+}
+
+func (g *Group) BufferSize(size int) *xorm.Session {
+	return g.Context(context.Background()).BufferSize(size)
+}
+
+func (g *Group) NoCache() *xorm.Session {
+	return g.Context(context.Background()).NoCache()
+}
+
+func (g *Group) NoCascade() *xorm.Session {
+	return g.Context(context.Background()).NoCascade()
+}
+
+func (g *Group) Cascade(trueOrFalse ...bool) *xorm.Session {
+	return g.Context(context.Background()).Cascade(trueOrFalse...)
+}
+
+func (g *Group) NoAutoCondition(no ...bool) *xorm.Session {
+	return g.Context(context.Background()).NoAutoCondition(no...)
+}
+
+func (g *Group) Sql(querystring string, args ...interface{}) *xorm.Session {
+	return g.Context(context.Background()).Sql(querystring, args...)
+}
+
+func (g *Group) Id(id interface{}) *xorm.Session {
+	return g.Context(context.Background()).Id(id)
+}
+
+func (g *Group) Before(closures func(interface{})) *xorm.Session {
+	return g.Context(context.Background()).Before(closures)
+}
+
+func (g *Group) After(closures func(interface{})) *xorm.Session {
+	return g.Context(context.Background()).After(closures)
+}
+
+func (g *Group) Charset(charset string) *xorm.Session {
+	return g.Context(context.Background()).Charset(charset)
+}
+
+func (g *Group) StoreEngine(storeEngine string) *xorm.Session {
+	return g.Context(context.Background()).StoreEngine(storeEngine)
+}
+
+func (g *Group) Distinct(columns ...string) *xorm.Session {
+	return g.Context(context.Background()).Distinct(columns...)
+}
+
+func (g *Group) AllCols() *xorm.Session {
+	return g.Context(context.Background()).AllCols()
+}
+
+func (g *Group) MustCols(columns ...string) *xorm.Session {
+	return g.Context(context.Background()).MustCols(columns...)
+}
+
+func (g *Group) UseBool(columns ...string) *xorm.Session {
+	return g.Context(context.Background()).UseBool(columns...)
+}
+
+func (g *Group) Omit(columns ...string) *xorm.Session {
+	return g.Context(context.Background()).Omit(columns...)
+}
+
+func (g *Group) Nullable(columns ...string) *xorm.Session {
+	return g.Context(context.Background()).Nullable(columns...)
+}
+
+func (g *Group) NotIn(column string, args ...interface{}) *xorm.Session {
+	return g.Context(context.Background()).NotIn(column, args...)
+}
+
+func (g *Group) Alias(alias string) *xorm.Session {
+	return g.Context(context.Background()).Alias(alias)
+}
+
+func (g *Group) Join(joinOperator string, tablename interface{}, condition string, args ...interface{}) *xorm.Session {
+	return g.Context(context.Background()).Join(joinOperator, tablename, condition, args...)
+}
+
+func (g *Group) GroupBy(keys string) *xorm.Session {
+	return g.Context(context.Background()).GroupBy(keys)
+}
+
+func (g *Group) Having(conditions string) *xorm.Session {
+	return g.Context(context.Background()).Having(conditions)
+}
+
+func (g *Group) Prepare() *xorm.Session {
+	return g.Context(context.Background()).Prepare()
+}
+
+func (g *Group) Unscoped() *xorm.Session {
+	return g.Context(context.Background()).Unscoped()
+}
+
+func (g *Group) Get(bean interface{}) (bool, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Get(bean)
+}
+
+func (g *Group) Find(beans interface{}, condiBeans ...interface{}) error {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Find(beans, condiBeans...)
+}
+
+func (g *Group) FindAndCount(rowsSlicePtr interface{}, condiBean ...interface{}) (int64, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.FindAndCount(rowsSlicePtr, condiBean...)
+}
+
+func (g *Group) Count(bean ...interface{}) (int64, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Count(bean...)
+}
+
+func (g *Group) Exist(bean ...interface{}) (bool, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Exist(bean...)
+}
+
+func (g *Group) Iterate(bean interface{}, fun xorm.IterFunc) error {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Iterate(bean, fun)
+}
+
+// Rows 返回的结果集关闭时释放会话
+func (g *Group) Rows(bean interface{}) (*xorm.Rows, error) {
+	return g.Context(context.Background()).Rows(bean)
+}
+
+func (g *Group) Sum(bean interface{}, colName string) (float64, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Sum(bean, colName)
+}
+
+func (g *Group) SumInt(bean interface{}, colName string) (int64, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.SumInt(bean, colName)
+}
+
+func (g *Group) Sums(bean interface{}, colNames ...string) ([]float64, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Sums(bean, colNames...)
+}
+
+func (g *Group) SumsInt(bean interface{}, colNames ...string) ([]int64, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.SumsInt(bean, colNames...)
+}
+
+func (g *Group) Query(sqlOrArgs ...interface{}) ([]map[string][]byte, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.Query(sqlOrArgs...)
+}
+
+func (g *Group) QueryString(sqlOrArgs ...interface{}) ([]map[string]string, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.QueryString(sqlOrArgs...)
+}
+
+func (g *Group) QueryInterface(sqlOrArgs ...interface{}) ([]map[string]interface{}, error) {
+	session := g.NewSession()
+	defer session.Close()
+	return session.QueryInterface(sqlOrArgs...)
+}
+
+func (g *Group) setDown(slave *xorm.Engine, down bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	changed := g.down[slave] != down
+	g.down[slave] = down
+	return changed
+}
+
+func (g *Group) setMasterDown(down bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	changed := g.masterDown != down
+	g.masterDown = down
+	return changed
+}
+
+// Healthy 返回主库是否可用及可用从库数量
+func (g *Group) Healthy() (bool, int) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	slaves := 0
+	for _, s := range g.Slaves() {
+		if !g.down[s] {
+			slaves++
+		}
+	}
+	return !g.masterDown, slaves
+}
+
+func (g *Group) healthy(slave *xorm.Engine) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return !g.down[slave]
+}
+
+func (g *Group) replicaAvailable() bool {
+	slaves := g.Slaves()
+	if len(slaves) == 0 {
+		return true
+	}
+	for _, s := range slaves {
+		if g.healthy(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *healthPolicy) Slave(eg *xorm.EngineGroup) *xorm.Engine {
+	slaves := eg.Slaves()
+	for i := 0; i < len(slaves); i++ {
+		if s := p.policy.Slave(eg); p.group.healthy(s) {
+			return s
+		}
+	}
+	for _, s := range slaves {
+		if p.group.healthy(s) {
+			return s
+		}
+	}
+	return eg.Master()
+}
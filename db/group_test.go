@@ -0,0 +1,96 @@
+package db
+
+import (
+	"testing"
+	"github.com/go-xorm/xorm"
+)
+
+type routeItem struct {
+	Id   int64
+	Name string
+}
+
+func TestGroupReadRouting(t *testing.T) {
+	eg, err := xorm.NewEngineGroup("sqlite3", []string{
+		"file:route_master?mode=memory&cache=shared",
+		"file:route_slave?mode=memory&cache=shared",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eg.Close()
+	eg.SetMaxIdleConns(1)
+	for i, e := range append([]*xorm.Engine{eg.Master()}, eg.Slaves()...) {
+		if _, err := e.Exec("CREATE TABLE route_item (id INTEGER, name TEXT)"); err != nil {
+			t.Fatal(err)
+		}
+		name := []string{"master", "slave"}[i]
+		if _, err := e.Exec("INSERT INTO route_item VALUES (?, ?)", i+1, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := newGroup("route", eg, adapters[AdapterSQLite])
+
+	get := func(s *xorm.Session) string {
+		var item routeItem
+		if _, err := s.Table("route_item").Get(&item); err != nil {
+			t.Fatal(err)
+		}
+		return item.Name
+	}
+	sessions := map[string]*xorm.Session{
+		"Alias":    g.Alias("r"),
+		"Join":     g.Join("INNER", []string{"route_item", "o"}, "o.id = route_item.id"),
+		"Distinct": g.Distinct("name"),
+		"GroupBy":  g.GroupBy("name"),
+		"NotIn":    g.NotIn("id", 0),
+		"Omit":     g.Omit("id"),
+		"Unscoped": g.Unscoped(),
+	}
+	for name, s := range sessions {
+		if got := get(s); got != "slave" {
+			t.Errorf("%s read from %s", name, got)
+		}
+	}
+
+	var name string
+	if _, err := g.SQL("SELECT name FROM route_item").Get(&name); err != nil || name != "slave" {
+		t.Errorf("SQL read from %s: %v", name, err)
+	}
+	if sum, err := g.Table("route_item").SumInt(new(routeItem), "id"); err != nil || sum != 2 {
+		t.Errorf("Table().SumInt = %d: %v", sum, err)
+	}
+	if sum, err := g.SumInt(new(routeItem), "id"); err != nil || sum != 2 {
+		t.Errorf("SumInt = %d: %v", sum, err)
+	}
+	if sums, err := g.Sums(new(routeItem), "id"); err != nil || len(sums) != 1 || sums[0] != 2 {
+		t.Errorf("Sums = %v: %v", sums, err)
+	}
+	err = g.Iterate(new(routeItem), func(i int, bean interface{}) error {
+		name = bean.(*routeItem).Name
+		return nil
+	})
+	if err != nil || name != "slave" {
+		t.Errorf("Iterate read from %s: %v", name, err)
+	}
+	rows, err := g.Rows(new(routeItem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var item routeItem
+		rows.Scan(&item)
+		name = item.Name
+	}
+	rows.Close()
+	if name != "slave" {
+		t.Errorf("Rows read from %s", name)
+	}
+
+	if _, err := g.Incr("id").Where("name = ?", "master").Update(new(routeItem)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := eg.Master().Where("id = 2").Count(new(routeItem)); n != 1 {
+		t.Errorf("Incr did not update master")
+	}
+}
@@ -0,0 +1,511 @@
+package db
+
+import (
+	"io"
+	"fmt"
+	"sync"
+	"time"
+	"regexp"
+	"context"
+	"strings"
+	"strconv"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/go-xorm/core"
+	"github.com/jinycoo/jinygo/log"
+)
+
+const (
+	logDriverPrefix = "jinygo:"
+	// logDsnSep 分隔 DSN 前的日志配置编号，编号由 registerSqlLogger 生成且不含该字符
+	logDsnSep = "|"
+	redacted = "******"
+)
+
+var (
+	// DefaultRedact 默认脱敏的列名关键字，列名包含其中任一关键字（不区分大小写）时参数以 ****** 记录
+	DefaultRedact = []string{"password", "passwd", "secret", "token"}
+
+	// sqlLoggers 以每次打开数据组时生成的编号索引，共用 DSN 的数据组互不覆盖，数据组关闭时删除
+	sqlLoggers = struct {
+		sync.RWMutex
+		seq  uint64
+		byId map[string]*sqlLogger
+	}{byId: make(map[string]*sqlLogger)}
+	logDrivers sync.Map
+
+	insertCols = regexp.MustCompile(`(?is)^\s*(?:insert|replace)\s+(?:into\s+)?\S+\s*\(([^)]*)\)\s*values`)
+	compareCol = regexp.MustCompile(`(?i)([\w."` + "`" + `]+)\s*(?:=|<>|!=|<=|>=|<|>|\s+like|\s+in\s*\()\s*\(?\s*$`)
+)
+
+type (
+	// sqlLogger 数据组的 SQL 日志配置，showSql 为 false 时仅记录慢查询及错误
+	sqlLogger struct {
+		group   string
+		showSql bool
+		slow    time.Duration
+		redact  []string
+	}
+
+	logDriver struct {
+		driver.Driver
+	}
+
+	// logConnector 由 sql.Open 创建，持有打开时的日志配置
+	logConnector struct {
+		driver    *logDriver
+		connector driver.Connector
+		dsn       string
+		logger    *sqlLogger
+	}
+
+	// logDsnDriver 去除日志配置编号后交由原驱动解析 DSN
+	logDsnDriver struct {
+		core.Driver
+	}
+
+	logConn struct {
+		driver.Conn
+		logger *sqlLogger
+	}
+
+	logStmt struct {
+		driver.Stmt
+		conn   *logConn
+		query  string
+		logger *sqlLogger
+	}
+
+	logRows struct {
+		driver.Rows
+		ctx    context.Context
+		query  string
+		args   []driver.NamedValue
+		start  time.Time
+		rows   int64
+		err    error
+		logger *sqlLogger
+	}
+
+	// xormLogger 将 xorm 自身日志输出至 log 包，SQL 由驱动层记录
+	xormLogger struct {
+		group string
+		level core.LogLevel
+	}
+)
+
+// logDriverName 注册包装了 SQL 日志的驱动，并向 xorm 注册同名的 DSN 解析器
+func logDriverName(name string) (string, error) {
+	wrapped := logDriverPrefix + name
+	if _, ok := logDrivers.Load(wrapped); ok {
+		return wrapped, nil
+	}
+	sqlDb, err := sql.Open(name, "")
+	if err != nil {
+		return "", err
+	}
+	d := sqlDb.Driver()
+	sqlDb.Close()
+	if _, loaded := logDrivers.LoadOrStore(wrapped, true); !loaded {
+		sql.Register(wrapped, &logDriver{Driver: d})
+		core.RegisterDriver(wrapped, &logDsnDriver{Driver: core.QueryDriver(name)})
+	}
+	return wrapped, nil
+}
+
+// registerSqlLogger 注册日志配置，返回携带编号的 DSN 生成函数及编号
+func registerSqlLogger(l *sqlLogger) (string, func(dsn string) string) {
+	sqlLoggers.Lock()
+	sqlLoggers.seq++
+	id := strconv.FormatUint(sqlLoggers.seq, 10)
+	sqlLoggers.byId[id] = l
+	sqlLoggers.Unlock()
+	return id, func(dsn string) string {
+		return id + logDsnSep + dsn
+	}
+}
+
+func unregisterSqlLogger(id string) {
+	sqlLoggers.Lock()
+	delete(sqlLoggers.byId, id)
+	sqlLoggers.Unlock()
+}
+
+// splitLogDsn 拆分日志配置编号及原 DSN，未携带编号时使用默认配置
+func splitLogDsn(name string) (*sqlLogger, string) {
+	var l *sqlLogger
+	if i := strings.Index(name, logDsnSep); i > 0 {
+		sqlLoggers.RLock()
+		l = sqlLoggers.byId[name[:i]]
+		sqlLoggers.RUnlock()
+		if l != nil {
+			name = name[i+1:]
+		}
+	}
+	if l == nil {
+		l = &sqlLogger{redact: DefaultRedact}
+	}
+	return l, name
+}
+
+func (d *logDsnDriver) Parse(driverName, dsn string) (*core.Uri, error) {
+	_, dsn = splitLogDsn(dsn)
+	return d.Driver.Parse(driverName, dsn)
+}
+
+func (d *logDriver) Open(name string) (driver.Conn, error) {
+	l, dsn := splitLogDsn(name)
+	conn, err := d.Driver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &logConn{Conn: conn, logger: l}, nil
+}
+
+// OpenConnector 在 sql.Open 时解析日志配置，此后的连接均使用该配置
+func (d *logDriver) OpenConnector(name string) (driver.Connector, error) {
+	l, dsn := splitLogDsn(name)
+	c := &logConnector{driver: d, dsn: dsn, logger: l}
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		c.connector = connector
+	}
+	return c, nil
+}
+
+func (c *logConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var (
+		conn driver.Conn
+		err  error
+	)
+	if c.connector != nil {
+		conn, err = c.connector.Connect(ctx)
+	} else {
+		conn, err = c.driver.Driver.Open(c.dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &logConn{Conn: conn, logger: c.logger}, nil
+}
+
+func (c *logConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c *logConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *logConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.logger.log(ctx, query, nil, 0, -1, err)
+		return nil, err
+	}
+	return &logStmt{Stmt: stmt, conn: c, query: query, logger: c.logger}, nil
+}
+
+func (c *logConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if level, ok := ctx.Value(txIsolationKey{}).(sql.IsolationLevel); ok && opts.Isolation == 0 {
+		opts.Isolation = driver.IsolationLevel(level)
+	}
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *logConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	if err == driver.ErrSkip {
+		return res, err
+	}
+	c.logger.log(ctx, query, args, time.Since(start), affected(res), err)
+	return res, err
+}
+
+func (c *logConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != nil {
+		if err != driver.ErrSkip {
+			c.logger.log(ctx, query, args, time.Since(start), -1, err)
+		}
+		return nil, err
+	}
+	return &logRows{Rows: rows, ctx: ctx, query: query, args: args, start: start, logger: c.logger}, nil
+}
+
+func (c *logConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *logConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue 交由原连接校验，原连接未实现时由 database/sql 默认转换
+func (c *logConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (s *logStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(values(args))
+	}
+	s.logger.log(ctx, s.query, args, time.Since(start), affected(res), err)
+	return res, err
+}
+
+func (s *logStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(values(args))
+	}
+	if err != nil {
+		s.logger.log(ctx, s.query, args, time.Since(start), -1, err)
+		return nil, err
+	}
+	return &logRows{Rows: rows, ctx: ctx, query: s.query, args: args, start: start, logger: s.logger}, nil
+}
+
+// CheckNamedValue 与 database/sql 的顺序一致，依次交由原语句、原连接校验
+func (s *logStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	if s.conn != nil {
+		return s.conn.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ColumnConverter 默认转换时使用原语句的转换器
+func (s *logStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+func (r *logRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.rows++
+	} else if err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+// Close 在结果集关闭时记录日志，耗时包含读取结果集的时间
+func (r *logRows) Close() error {
+	err := r.Rows.Close()
+	r.logger.log(r.ctx, r.query, r.args, time.Since(r.start), r.rows, r.err)
+	return err
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		vals[i] = a.Value
+	}
+	return vals
+}
+
+func affected(res driver.Result) int64 {
+	if res == nil {
+		return -1
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func (l *sqlLogger) log(ctx context.Context, query string, args []driver.NamedValue, d time.Duration, rows int64, err error) {
+	slow := l.slow > 0 && d >= l.slow
+	if err == nil && !slow && !l.showSql {
+		return
+	}
+	fields := map[string]interface{}{
+		"db":          l.group,
+		"sql":         query,
+		"duration_ms": float64(d.Nanoseconds()) / float64(time.Millisecond),
+	}
+	if len(args) > 0 {
+		fields["args"] = l.redactArgs(query, args)
+	}
+	if rows >= 0 {
+		fields["rows"] = rows
+	}
+	if id := log.RequestId(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	switch {
+	case err != nil:
+		fields["error"] = err.Error()
+		log.CError("SQL error", fields)
+	case slow:
+		log.CWarn("Slow SQL", fields)
+	default:
+		log.CInfo("SQL", fields)
+	}
+}
+
+// redactArgs 根据参数对应的列名脱敏，INSERT 按列顺序对应，其余语句取占位符前比较运算的列名
+func (l *sqlLogger) redactArgs(query string, args []driver.NamedValue) string {
+	cols := placeholderColumns(query, len(args))
+	parts := make([]string, len(args))
+	for i, a := range args {
+		col := ""
+		if a.Name != "" {
+			col = a.Name
+		} else if i < len(cols) {
+			col = cols[i]
+		}
+		if l.sensitive(col) {
+			parts[i] = redacted
+			continue
+		}
+		switch v := a.Value.(type) {
+		case []byte:
+			parts[i] = fmt.Sprintf("%q", truncateArg(string(v)))
+		case string:
+			parts[i] = fmt.Sprintf("%q", truncateArg(v))
+		case time.Time:
+			parts[i] = v.Format(time.RFC3339)
+		default:
+			parts[i] = fmt.Sprint(v)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func (l *sqlLogger) sensitive(col string) bool {
+	col = strings.ToLower(col)
+	if col == "" {
+		return false
+	}
+	for _, r := range l.redact {
+		if strings.Contains(col, strings.ToLower(r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderColumns 返回 n 个参数各自对应的列名，多行 INSERT 的参数按列数循环对应
+func placeholderColumns(query string, n int) []string {
+	cols := make([]string, 0, n)
+	if m := insertCols.FindStringSubmatch(query); m != nil {
+		names := strings.Split(m[1], ",")
+		for i := 0; i < n; i++ {
+			cols = append(cols, strings.Trim(strings.TrimSpace(names[i % len(names)]), "`\""))
+		}
+		return cols
+	}
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' && !(query[i] == '$' && i + 1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9') {
+			continue
+		}
+		col := ""
+		if m := compareCol.FindStringSubmatch(query[:i]); m != nil {
+			col = m[1]
+			if dot := strings.LastIndex(col, "."); dot >= 0 {
+				col = col[dot+1:]
+			}
+			col = strings.Trim(col, "`\"")
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+func truncateArg(s string) string {
+	if len(s) > 256 {
+		return s[:256] + "..."
+	}
+	return s
+}
+
+func (l *xormLogger) Debug(v ...interface{}) {
+	if l.level <= core.LOG_DEBUG {
+		log.Debug(append([]interface{}{l.group, " "}, v...)...)
+	}
+}
+func (l *xormLogger) Debugf(format string, v ...interface{}) {
+	l.Debug(fmt.Sprintf(format, v...))
+}
+func (l *xormLogger) Info(v ...interface{}) {
+	if l.level <= core.LOG_INFO {
+		log.Info(append([]interface{}{l.group, " "}, v...)...)
+	}
+}
+func (l *xormLogger) Infof(format string, v ...interface{}) {
+	l.Info(fmt.Sprintf(format, v...))
+}
+func (l *xormLogger) Warn(v ...interface{}) {
+	if l.level <= core.LOG_WARNING {
+		log.Warn(append([]interface{}{l.group, " "}, v...)...)
+	}
+}
+func (l *xormLogger) Warnf(format string, v ...interface{}) {
+	l.Warn(fmt.Sprintf(format, v...))
+}
+func (l *xormLogger) Error(v ...interface{}) {
+	if l.level <= core.LOG_ERR {
+		log.Error(append([]interface{}{l.group, " "}, v...)...)
+	}
+}
+func (l *xormLogger) Errorf(format string, v ...interface{}) {
+	l.Error(fmt.Sprintf(format, v...))
+}
+func (l *xormLogger) Level() core.LogLevel     { return l.level }
+func (l *xormLogger) SetLevel(lv core.LogLevel) { l.level = lv }
+func (l *xormLogger) ShowSQL(show ...bool)      {}
+func (l *xormLogger) IsShowSQL() bool           { return false }
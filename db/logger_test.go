@@ -0,0 +1,138 @@
+package db
+
+import (
+	"io"
+	"errors"
+	"context"
+	"testing"
+	"database/sql"
+	"database/sql/driver"
+)
+
+func TestRedactArgs(t *testing.T) {
+	l := &sqlLogger{redact: DefaultRedact}
+	cases := []struct {
+		query string
+		args  []interface{}
+		want  string
+	}{
+		{
+			"INSERT INTO `user` (`name`, `password`) VALUES (?, ?)",
+			[]interface{}{"jiny", "p1"},
+			`["jiny", ******]`,
+		},
+		{
+			"INSERT INTO `user` (`name`, `password`) VALUES (?, ?),(?, ?),(?, ?)",
+			[]interface{}{"a", "p1", "b", "p2", "c", "p3"},
+			`["a", ******, "b", ******, "c", ******]`,
+		},
+		{
+			"SELECT * FROM user WHERE name = ? AND password = ?",
+			[]interface{}{"jiny", "p1"},
+			`["jiny", ******]`,
+		},
+		{
+			"UPDATE user SET token = $1 WHERE id = $2",
+			[]interface{}{"t1", int64(1)},
+			`[******, 1]`,
+		},
+	}
+	for _, c := range cases {
+		args := make([]driver.NamedValue, len(c.args))
+		for i, v := range c.args {
+			args[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+		}
+		if got := l.redactArgs(c.query, args); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.query, got, c.want)
+		}
+	}
+}
+
+type errRows struct {
+	driver.Rows
+	err error
+}
+
+func (r *errRows) Next(dest []driver.Value) error { return r.err }
+func (r *errRows) Close() error                   { return nil }
+
+func TestLogRowsError(t *testing.T) {
+	broken := errors.New("connection reset")
+	for _, c := range []struct {
+		next error
+		want error
+	}{
+		{nil, nil},
+		{io.EOF, nil},
+		{broken, broken},
+	} {
+		r := &logRows{Rows: &errRows{err: c.next}, logger: &sqlLogger{}}
+		r.Next(nil)
+		if r.err != c.want {
+			t.Errorf("err = %v, want %v", r.err, c.want)
+		}
+	}
+}
+
+func TestSqlLoggerPerOpen(t *testing.T) {
+	name, err := logDriverName("sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := "file:logger_per_open?mode=memory&cache=shared"
+	loggers := []*sqlLogger{{group: "a"}, {group: "b"}}
+	ids := make([]string, 0, len(loggers))
+	for _, l := range loggers {
+		id, logDsn := registerSqlLogger(l)
+		ids = append(ids, id)
+		sqlDb, err := sql.Open(name, logDsn(dsn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 注册信息删除后已打开的连接池仍使用打开时的配置
+		unregisterSqlLogger(id)
+		conn, err := sqlDb.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Raw(func(dc interface{}) error {
+			if got := dc.(*logConn).logger; got != l {
+				t.Errorf("logger = %s, want %s", got.group, l.group)
+			}
+			return nil
+		})
+		conn.Close()
+		sqlDb.Close()
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("duplicate logger id %s", ids[0])
+	}
+	if len(sqlLoggers.byId) != 0 {
+		t.Errorf("loggers leaked: %v", sqlLoggers.byId)
+	}
+}
+
+type checkConn struct {
+	driver.Conn
+	checked int
+}
+
+func (c *checkConn) CheckNamedValue(nv *driver.NamedValue) error {
+	c.checked++
+	return nil
+}
+
+func TestCheckNamedValue(t *testing.T) {
+	conn := &checkConn{}
+	lc := &logConn{Conn: conn, logger: &sqlLogger{}}
+	stmt := &logStmt{Stmt: &plainStmt{}, conn: lc, logger: lc.logger}
+	if err := stmt.CheckNamedValue(&driver.NamedValue{Value: 1}); err != nil || conn.checked != 1 {
+		t.Errorf("stmt check: %v, conn checked %d", err, conn.checked)
+	}
+	if err := (&logConn{Conn: &plainConn{}}).CheckNamedValue(&driver.NamedValue{}); err != driver.ErrSkip {
+		t.Errorf("conn without checker: %v", err)
+	}
+}
+
+type plainStmt struct{ driver.Stmt }
+type plainConn struct{ driver.Conn }
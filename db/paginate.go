@@ -0,0 +1,75 @@
+package db
+
+import (
+	"fmt"
+	"errors"
+	"reflect"
+	"strings"
+	"github.com/go-xorm/xorm"
+	"github.com/jinycoo/jinygo/utils"
+)
+
+// Paginator 分页参数，web.Pagination 实现了该接口
+type Paginator interface {
+	PageLimit() int
+	PageOffset() int
+	PageCursor() (column string, value interface{}, ok bool)
+	SetTotal(total int64)
+	SetNextCursor(value interface{}) error
+}
+
+// FindPage 按分页参数查询并统计总数
+// 偏移分页使用 limit/offset；游标分页使用 column > cursor 条件并按 column 升序，
+// 此时 total 为游标之后的记录数
+func FindPage(sess *xorm.Session, p Paginator, rowsSlicePtr interface{}) error {
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if sliceValue.Kind() != reflect.Slice {
+		return errors.New("rowsSlicePtr must be a pointer to slice")
+	}
+	column, cursor, keyset := p.PageCursor()
+	if !keyset {
+		total, err := sess.Limit(p.PageLimit(), p.PageOffset()).FindAndCount(rowsSlicePtr)
+		if err != nil {
+			return err
+		}
+		p.SetTotal(total)
+		return nil
+	}
+
+	if cursor != nil {
+		sess = sess.And(fmt.Sprintf("%s > ?", column), cursor)
+	}
+	total, err := sess.Asc(column).Limit(p.PageLimit()).FindAndCount(rowsSlicePtr)
+	if err != nil {
+		return err
+	}
+	p.SetTotal(total)
+	if n := sliceValue.Len(); n > 0 && int64(n) < total {
+		if v, ok := columnValue(sliceValue.Index(n-1), column); ok {
+			return p.SetNextCursor(v)
+		}
+	}
+	return p.SetNextCursor(nil)
+}
+
+// columnValue 返回行中 column 列的值，保留原始类型以便游标按类型编码
+func columnValue(row reflect.Value, column string) (interface{}, bool) {
+	row = reflect.Indirect(row)
+	switch row.Kind() {
+	case reflect.Map:
+		v := row.MapIndex(reflect.ValueOf(column))
+		if !v.IsValid() {
+			return nil, false
+		}
+		return v.Interface(), true
+	case reflect.Struct:
+		t := row.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if utils.SnakeString(f.Name) == column || strings.Contains(f.Tag.Get("xorm"), "'"+column+"'") {
+				return row.Field(i).Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"fmt"
+	"github.com/go-xorm/xorm"
+)
+
+const (
+	PolicyRandom           = "random"
+	PolicyWeightRandom     = "weight_random"
+	PolicyRoundRobin       = "round_robin"
+	PolicyWeightRoundRobin = "weight_round_robin"
+	PolicyLeastConn        = "least_conn"
+)
+
+// groupPolicy 根据配置返回从库负载策略，未配置时与 xorm 默认一致使用随机策略
+func groupPolicy(name string, slaves []*slaveConfig) (xorm.GroupPolicy, error) {
+	switch name {
+	case "", PolicyRandom:
+		return xorm.RandomPolicy(), nil
+	case PolicyWeightRandom:
+		return xorm.WeightRandomPolicy(slaveWeights(slaves)), nil
+	case PolicyRoundRobin:
+		return xorm.RoundRobinPolicy(), nil
+	case PolicyWeightRoundRobin:
+		return xorm.WeightRoundRobinPolicy(slaveWeights(slaves)), nil
+	case PolicyLeastConn:
+		return xorm.LeastConnPolicy(), nil
+	}
+	return nil, fmt.Errorf("db: unknown policy %q", name)
+}
+
+func slaveWeights(slaves []*slaveConfig) []int {
+	weights := make([]int, len(slaves))
+	for i, s := range slaves {
+		weights[i] = s.Weight
+		if weights[i] <= 0 {
+			weights[i] = 1
+		}
+	}
+	return weights
+}
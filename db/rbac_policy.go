@@ -0,0 +1,93 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"crypto/sha256"
+	"github.com/jinycoo/jinygo/rbac"
+)
+
+// RbacLoader 从数据表读取 rbac 策略，实现 rbac.Loader，数据未变化时 Load 返回 nil
+// 角色权限表字段为 role、permission，角色继承表字段为 role、parent，路由规则表字段为 method、path、permission、owner
+type RbacLoader struct {
+	dbName          string
+	PermissionTable string
+	InheritTable    string
+	RouteTable      string
+	sum             []byte
+}
+
+func RbacPolicy(dbName string) *RbacLoader {
+	return &RbacLoader{
+		dbName:          dbName,
+		PermissionTable: "rbac_role_permissions",
+		InheritTable:    "rbac_role_inherits",
+		RouteTable:      "rbac_routes",
+	}
+}
+
+func (l *RbacLoader) Load() (*rbac.Policy, error) {
+	engine := Use(l.dbName)
+	if engine == nil {
+		return nil, errors.New(l.dbName + " - Database does not exist.")
+	}
+	policy := &rbac.Policy{Roles: make(map[string]*rbac.Role)}
+	role := func(name string) *rbac.Role {
+		r, ok := policy.Roles[name]
+		if !ok {
+			r = &rbac.Role{}
+			policy.Roles[name] = r
+		}
+		return r
+	}
+
+	// 按固定顺序读取并计算摘要，用于判断策略是否变化
+	h := sha256.New()
+	digest := func(values ...string) {
+		for _, v := range values {
+			h.Write([]byte(v))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{'\n'})
+	}
+
+	rows, err := engine.QueryString("SELECT role, permission FROM " + l.PermissionTable + " ORDER BY role, permission")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		digest("p", row["role"], row["permission"])
+		r := role(row["role"])
+		r.Permissions = append(r.Permissions, row["permission"])
+	}
+	if l.InheritTable != "" {
+		rows, err = engine.QueryString("SELECT role, parent FROM " + l.InheritTable + " ORDER BY role, parent")
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			digest("i", row["role"], row["parent"])
+			r := role(row["role"])
+			r.Inherits = append(r.Inherits, row["parent"])
+		}
+	}
+	rows, err = engine.QueryString("SELECT method, path, permission, owner FROM " + l.RouteTable + " ORDER BY path, method DESC, permission, owner")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		digest("r", row["method"], row["path"], row["permission"], row["owner"])
+		policy.Routes = append(policy.Routes, &rbac.Rule{
+			Method:     row["method"],
+			Path:       row["path"],
+			Permission: row["permission"],
+			Owner:      row["owner"],
+		})
+	}
+	sum := h.Sum(nil)
+	if bytes.Equal(sum, l.sum) {
+		return nil, nil
+	}
+	l.sum = sum
+	return policy, nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+	"github.com/go-xorm/xorm"
+	"github.com/jinycoo/jinygo/log"
+)
+
+func TestRbacLoaderChanges(t *testing.T) {
+	log.New(nil)
+	group, err := xorm.NewEngineGroup("sqlite3", []string{"file:rbac_policy?mode=memory&cache=shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.SetMaxIdleConns(1)
+	Register("rbac_policy", group)
+	defer func() {
+		delete(DataGroup, "rbac_policy")
+		group.Close()
+	}()
+	for _, sql := range []string{
+		"CREATE TABLE rbac_role_permissions (role TEXT, permission TEXT)",
+		"CREATE TABLE rbac_role_inherits (role TEXT, parent TEXT)",
+		"CREATE TABLE rbac_routes (method TEXT, path TEXT, permission TEXT, owner TEXT)",
+		"INSERT INTO rbac_role_permissions VALUES ('viewer', 'article:read')",
+		"INSERT INTO rbac_role_inherits VALUES ('editor', 'viewer')",
+		"INSERT INTO rbac_routes VALUES ('GET', '/article', 'article:read', '')",
+	} {
+		if _, err := group.Exec(sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loader := RbacPolicy("rbac_policy")
+	policy, err := loader.Load()
+	if err != nil || policy == nil {
+		t.Fatalf("first load: %v, %v", policy, err)
+	}
+	if r := policy.Roles["editor"]; r == nil || len(r.Inherits) != 1 || len(policy.Routes) != 1 {
+		t.Fatalf("policy = %+v", policy)
+	}
+	if policy, err = loader.Load(); err != nil || policy != nil {
+		t.Fatalf("unchanged load: %v, %v", policy, err)
+	}
+	if _, err := group.Exec("INSERT INTO rbac_role_permissions VALUES ('editor', 'article:update')"); err != nil {
+		t.Fatal(err)
+	}
+	if policy, err = loader.Load(); err != nil || policy == nil {
+		t.Fatalf("changed load: %v, %v", policy, err)
+	}
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"fmt"
+	"time"
+	"context"
+	"strconv"
+	"github.com/go-xorm/xorm"
+	"github.com/jinycoo/jinygo/log"
+)
+
+const (
+	DefaultHealthCheck = 5
+
+	pingTimeout = 3 * time.Second
+	minBackoff  = 500 * time.Millisecond
+	maxBackoff  = 10 * time.Second
+)
+
+// replicaLag 查询从库复制延迟，adapter 不支持时返回 0
+func (g *Group) replicaLag(slave *xorm.Engine) (time.Duration, error) {
+	if g.adapter == nil {
+		return 0, nil
+	}
+	switch g.adapter.driver {
+	case AdapterMySQL:
+		rows, err := slave.QueryString("SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+		if len(rows) == 0 {
+			return 0, nil
+		}
+		seconds, err := strconv.ParseFloat(rows[0]["Seconds_Behind_Master"], 64)
+		if err != nil {
+			return 0, fmt.Errorf("replication is not running")
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	case AdapterPostgres:
+		rows, err := slave.QueryString("SELECT COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) AS lag")
+		if err != nil {
+			return 0, err
+		}
+		if len(rows) == 0 {
+			return 0, nil
+		}
+		seconds, _ := strconv.ParseFloat(rows[0]["lag"], 64)
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return 0, nil
+}
+
+func ping(engine *xorm.Engine) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return engine.PingContext(ctx)
+}
+
+// connect 在 deadline 前按指数退避重试连接主库，并逐个检查从库
+func (g *Group) connect(deadline time.Time) error {
+	backoff := minBackoff
+	for {
+		err := ping(g.Master())
+		if err == nil {
+			break
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			g.setMasterDown(true)
+			return err
+		}
+		log.Warn(fmt.Sprintf("%s 主库连接失败，%s 后重试：%v", g.name, backoff, err))
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	g.checkReplicas()
+	return nil
+}
+
+// check 健康检查，记录主库状态变化并检查从库
+func (g *Group) check() {
+	err := ping(g.Master())
+	if g.setMasterDown(err != nil) {
+		if err != nil {
+			log.Error(g.name + " 主库连接失败：" + err.Error())
+		} else {
+			log.Info(g.name + " 主库连接恢复")
+		}
+	}
+	g.checkReplicas()
+}
+
+// checkReplicas 检查从库连接及复制延迟，连接失败或延迟超过 maxLag 的从库暂停读取
+func (g *Group) checkReplicas() {
+	for i, slave := range g.Slaves() {
+		var lag time.Duration
+		err := ping(slave)
+		if err == nil && g.maxLag > 0 {
+			lag, err = g.replicaLag(slave)
+		}
+		down := err != nil || g.maxLag > 0 && lag > g.maxLag
+		if !g.setDown(slave, down) {
+			continue
+		}
+		name := fmt.Sprintf("%s slave[%d]", g.name, i)
+		switch {
+		case err != nil:
+			log.Warn(name + " 检查失败，读操作暂停使用该从库：" + err.Error())
+		case down:
+			log.Warn(fmt.Sprintf("%s 复制延迟 %s 超过 %s，读操作暂停使用该从库", name, lag, g.maxLag))
+		default:
+			log.Info(name + " 恢复正常")
+		}
+	}
+}
+
+// monitor 每隔 interval 检查从库状态，直至数据组关闭
+func (g *Group) monitor(interval time.Duration) {
+	g.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				g.check()
+			}
+		}
+	}(g.stop)
+}
+
+func (g *Group) Close() error {
+	if g.stop != nil {
+		close(g.stop)
+		g.stop = nil
+	}
+	unregisterSqlLogger(g.logId)
+	return g.EngineGroup.Close()
+}
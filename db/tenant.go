@@ -0,0 +1,448 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"errors"
+	"regexp"
+	"context"
+	"strings"
+	"container/list"
+	"github.com/go-xorm/xorm"
+	"github.com/jinycoo/jinygo/log"
+)
+
+const (
+	DefaultTenantPools  = 100
+	DefaultTenantIdle   = 600
+	DefaultTenantTable  = "tenants"
+	DefaultTenantDbName = "tenant_{tenant}"
+
+	// TenantGroupPrefix 租户数据组名前缀，用于日志及事务
+	TenantGroupPrefix = "tenant:"
+)
+
+var (
+	// Tenants 多租户注册表，database.yml 未配置 tenant 时为 nil
+	Tenants *TenantRegistry
+
+	ErrTenantDisabled    = errors.New("db: multi-tenant is not configured")
+	ErrTenantMissing     = errors.New("db: tenant is missing in context")
+	ErrTenantNotFound    = errors.New("db: tenant does not exist")
+	ErrTenantClosed      = errors.New("db: tenant registry is closed")
+	ErrTenantNotAcquired = errors.New("db: tenant group is not acquired in context, use db.AcquireTenant")
+
+	tenantIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+type (
+	// tenantConfig 多租户配置，租户数据组以 template 为模板创建，数据库名默认为 dbName 中 {tenant} 替换为租户 ID
+	// 租户来自 tenants 配置或 registry 数据组的 table 表，maxPools 为同时打开的连接池上限，idle 秒未使用的连接池被关闭
+	tenantConfig struct {
+		Template *dbGroupConfig         `yaml:"template"`
+		DbName   string                 `yaml:"dbName"`
+		Registry string                 `yaml:"registry"`
+		Table    string                 `yaml:"table"`
+		MaxPools int                    `yaml:"maxPools"`
+		Idle     int                    `yaml:"idle"`
+		Tenants  map[string]*TenantInfo `yaml:"tenants"`
+	}
+
+	// TenantInfo 租户数据库，未设置的字段使用模板配置，设置 host 或 port 时不使用模板的从库
+	TenantInfo struct {
+		Tenant string `yaml:"-" xorm:"tenant"`
+		DbName string `yaml:"dbName" xorm:"db_name"`
+		Host   string `yaml:"host" xorm:"host"`
+		Port   int    `yaml:"port" xorm:"port"`
+	}
+
+	// TenantLoader 按租户 ID 查询租户数据库，租户不存在时返回 nil
+	TenantLoader func(tenant string) (*TenantInfo, error)
+
+	// TenantRegistry 租户数据组注册表，首次使用时创建数据组，超过上限时淘汰最久未使用的连接池
+	// 数据组按引用计数，被淘汰或空闲的连接池在最后一个使用者释放后关闭
+	TenantRegistry struct {
+		cfg    *tenantConfig
+		loader TenantLoader
+
+		mu      sync.Mutex
+		entries map[string]*list.Element
+		lru     *list.List
+		loading map[string]*tenantCall
+		closed  bool
+		stop    chan struct{}
+
+		// closeGroup 关闭被淘汰的数据组，测试中替换以统计关闭次数
+		closeGroup func(g *Group) error
+	}
+
+	tenantEntry struct {
+		id      string
+		group   *Group
+		used    time.Time
+		refs    int
+		evicted bool
+	}
+
+	tenantCall struct {
+		done  chan struct{}
+		group *Group
+		err   error
+	}
+
+	tenantKey struct{}
+
+	// tenantRef 上下文中持有引用的租户数据组
+	tenantRef struct {
+		id    string
+		group *Group
+	}
+
+	tenantRefKey struct{}
+)
+
+func newTenantRegistry(cfg *tenantConfig) (*TenantRegistry, error) {
+	if cfg.Template == nil || cfg.Template.Master == nil {
+		return nil, errors.New("db: tenant template master must be setting")
+	}
+	if cfg.Template.Master.Dsn != "" {
+		return nil, errors.New("db: tenant template master must not use dsn")
+	}
+	if cfg.DbName == "" {
+		cfg.DbName = DefaultTenantDbName
+	}
+	if cfg.Table == "" {
+		cfg.Table = DefaultTenantTable
+	}
+	if cfg.MaxPools <= 0 {
+		cfg.MaxPools = DefaultTenantPools
+	}
+	if cfg.Idle <= 0 {
+		cfg.Idle = DefaultTenantIdle
+	}
+	if cfg.Registry != "" {
+		if _, ok := DataGroup[cfg.Registry]; !ok {
+			return nil, errors.New("db: tenant registry " + cfg.Registry + " - Database does not exist.")
+		}
+	}
+	r := &TenantRegistry{
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		loading: make(map[string]*tenantCall),
+		stop:    make(chan struct{}),
+
+		closeGroup: (*Group).Close,
+	}
+	if cfg.Registry != "" {
+		r.loader = r.tableLoader
+	}
+	go r.janitor(time.Duration(cfg.Idle) * time.Second)
+	return r, nil
+}
+
+// SetLoader 替换租户表查询，未在 tenants 中配置的租户通过 loader 查询
+func (r *TenantRegistry) SetLoader(loader TenantLoader) {
+	r.mu.Lock()
+	r.loader = loader
+	r.mu.Unlock()
+}
+
+// Acquire 返回租户的数据组并增加引用计数，使用完毕后须调用 release
+// 未打开时按配置创建，同一租户的并发请求只创建一次
+func (r *TenantRegistry) Acquire(tenant string) (group *Group, release func(), err error) {
+	if !tenantIdPattern.MatchString(tenant) {
+		return nil, nil, ErrTenantNotFound
+	}
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, nil, ErrTenantClosed
+		}
+		if el, ok := r.entries[tenant]; ok {
+			e := el.Value.(*tenantEntry)
+			e.used = time.Now()
+			e.refs++
+			r.lru.MoveToFront(el)
+			r.mu.Unlock()
+			return e.group, r.releaser(e), nil
+		}
+		call, ok := r.loading[tenant]
+		if !ok {
+			break
+		}
+		r.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, nil, call.err
+		}
+	}
+	call := &tenantCall{done: make(chan struct{})}
+	r.loading[tenant] = call
+	loader := r.loader
+	r.mu.Unlock()
+
+	call.group, call.err = r.open(tenant, loader)
+
+	var (
+		entry   *tenantEntry
+		evicted []*tenantEntry
+	)
+	r.mu.Lock()
+	delete(r.loading, tenant)
+	if call.err == nil {
+		if r.closed {
+			r.closeGroup(call.group)
+			call.group, call.err = nil, ErrTenantClosed
+		} else {
+			entry = &tenantEntry{id: tenant, group: call.group, used: time.Now(), refs: 1}
+			r.entries[tenant] = r.lru.PushFront(entry)
+			for r.lru.Len() > r.cfg.MaxPools {
+				if e := r.remove(r.lru.Back()); e.refs == 0 {
+					evicted = append(evicted, e)
+				}
+			}
+		}
+	}
+	r.mu.Unlock()
+	close(call.done)
+	for _, e := range evicted {
+		r.closeTenant(e)
+	}
+	if call.err != nil {
+		return nil, nil, call.err
+	}
+	return call.group, r.releaser(entry), nil
+}
+
+// releaser 返回只生效一次的释放函数，已淘汰的数据组在引用归零时关闭
+func (r *TenantRegistry) releaser(e *tenantEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			e.refs--
+			e.used = time.Now()
+			closeNow := e.refs == 0 && e.evicted
+			r.mu.Unlock()
+			if closeNow {
+				r.closeTenant(e)
+			}
+		})
+	}
+}
+
+// Close 关闭全部租户数据组，仍在使用的数据组在释放后关闭
+func (r *TenantRegistry) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.stop)
+	var idle []*tenantEntry
+	for r.lru.Len() > 0 {
+		if e := r.remove(r.lru.Back()); e.refs == 0 {
+			idle = append(idle, e)
+		}
+	}
+	r.mu.Unlock()
+	for _, e := range idle {
+		r.closeTenant(e)
+	}
+}
+
+func (r *TenantRegistry) open(tenant string, loader TenantLoader) (*Group, error) {
+	info, ok := r.cfg.Tenants[tenant]
+	if !ok {
+		if loader == nil {
+			return nil, ErrTenantNotFound
+		}
+		var err error
+		if info, err = loader(tenant); err != nil {
+			return nil, err
+		}
+		if info == nil {
+			return nil, ErrTenantNotFound
+		}
+	}
+	if info == nil {
+		info = &TenantInfo{}
+	}
+	e := r.groupConfig(info)
+	dbname := info.DbName
+	if dbname == "" {
+		dbname = strings.Replace(r.cfg.DbName, "{tenant}", tenant, -1)
+	}
+	group, err := openGroup(TenantGroupPrefix + tenant, dbname, e)
+	if err != nil {
+		return nil, err
+	}
+	// 请求中创建的连接池不重试，连接失败直接返回错误
+	if err = group.connect(time.Now()); err != nil {
+		group.Close()
+		return nil, err
+	}
+	if interval := healthInterval(e); interval > 0 {
+		group.monitor(interval)
+	}
+	log.Info(fmt.Sprintf("%s EngineGroup Opened", group.name))
+	return group, nil
+}
+
+// groupConfig 以模板生成租户数据组配置
+func (r *TenantRegistry) groupConfig(info *TenantInfo) *dbGroupConfig {
+	e := *r.cfg.Template
+	master := *e.Master
+	if info.Host != "" || info.Port != 0 {
+		if info.Host != "" {
+			master.Host = info.Host
+		}
+		if info.Port != 0 {
+			master.Port = info.Port
+		}
+		e.Slaves = nil
+	}
+	e.Master = &master
+	return &e
+}
+
+// tableLoader 从 registry 数据组的租户表查询，表字段为 tenant、db_name、host、port
+func (r *TenantRegistry) tableLoader(tenant string) (*TenantInfo, error) {
+	engine := Master(r.cfg.Registry)
+	if engine == nil {
+		return nil, errors.New(r.cfg.Registry + " - Database does not exist.")
+	}
+	info := new(TenantInfo)
+	has, err := engine.Table(r.cfg.Table).Where("tenant = ?", tenant).Get(info)
+	if err != nil || !has {
+		return nil, err
+	}
+	return info, nil
+}
+
+// remove 将数据组移出注册表，调用方在引用为 0 时关闭，否则由最后一个使用者释放时关闭
+func (r *TenantRegistry) remove(el *list.Element) *tenantEntry {
+	e := r.lru.Remove(el).(*tenantEntry)
+	e.evicted = true
+	delete(r.entries, e.id)
+	return e
+}
+
+// janitor 关闭超过 idle 未使用且无使用者的连接池
+func (r *TenantRegistry) janitor(idle time.Duration) {
+	interval := idle / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			var expired []*tenantEntry
+			r.mu.Lock()
+			for el := r.lru.Back(); el != nil; {
+				e, prev := el.Value.(*tenantEntry), el.Prev()
+				if e.refs == 0 && time.Since(e.used) >= idle {
+					expired = append(expired, r.remove(el))
+				}
+				el = prev
+			}
+			r.mu.Unlock()
+			for _, e := range expired {
+				r.closeTenant(e)
+			}
+		}
+	}
+}
+
+func (r *TenantRegistry) closeTenant(e *tenantEntry) {
+	r.closeGroup(e.group)
+	log.Info(fmt.Sprintf("%s EngineGroup Closed", e.group.name))
+}
+
+// WithTenant 将租户 ID 写入上下文，未配置 db 多租户时由 web.Tenant 中间件调用
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// AcquireTenant 将租户 ID 及持有引用的数据组写入上下文，使用完毕后须调用 release
+// web.Tenant 中间件在请求期间持有引用，后台任务使用租户数据组前须调用此方法
+func AcquireTenant(ctx context.Context, tenant string) (context.Context, func(), error) {
+	if Tenants == nil {
+		return ctx, nil, ErrTenantDisabled
+	}
+	g, release, err := Tenants.Acquire(tenant)
+	if err != nil {
+		return ctx, nil, err
+	}
+	ctx = context.WithValue(WithTenant(ctx, tenant), tenantRefKey{}, &tenantRef{id: tenant, group: g})
+	return ctx, release, nil
+}
+
+// TenantId 返回上下文中的租户 ID
+func TenantId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant
+	}
+	return ""
+}
+
+// TenantGroup 返回上下文中由 AcquireTenant 持有引用的租户数据组
+func TenantGroup(ctx context.Context) (*Group, error) {
+	if Tenants == nil {
+		return nil, ErrTenantDisabled
+	}
+	tenant := TenantId(ctx)
+	if tenant == "" {
+		return nil, ErrTenantMissing
+	}
+	if ref, ok := ctx.Value(tenantRefKey{}).(*tenantRef); ok && ref.id == tenant {
+		return ref.group, nil
+	}
+	return nil, ErrTenantNotAcquired
+}
+
+// Tenant 返回上下文中租户的数据组，租户不存在或连接失败时记录日志并返回 nil
+func Tenant(ctx context.Context) *Group {
+	g, err := TenantGroup(ctx)
+	if err != nil {
+		log.Error(TenantId(ctx) + " - Tenant database error: " + err.Error())
+		return nil
+	}
+	return g
+}
+
+// TenantContext 返回租户数据组绑定 ctx 的读写分离会话，规则同 UseContext
+func TenantContext(ctx context.Context) *xorm.Session {
+	if g := Tenant(ctx); g != nil {
+		return g.Context(ctx)
+	}
+	return nil
+}
+
+// TenantTx 在租户数据组的事务中执行 fn，规则同 Tx，上下文未持有数据组时在事务期间持有引用
+func TenantTx(ctx context.Context, fn func(s *Session) error, opts ...TxOption) error {
+	g, err := TenantGroup(ctx)
+	if err == ErrTenantNotAcquired {
+		var release func()
+		if ctx, release, err = AcquireTenant(ctx, TenantId(ctx)); err != nil {
+			return err
+		}
+		defer release()
+		g, err = TenantGroup(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	return g.Tx(ctx, fn, opts...)
+}
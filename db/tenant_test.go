@@ -0,0 +1,84 @@
+package db
+
+import (
+	"testing"
+	"container/list"
+)
+
+// testRegistry 返回不打开连接的注册表，closed 记录各租户数据组的关闭次数
+func testRegistry(maxPools int, ids ...string) (*TenantRegistry, map[string]int) {
+	closed := make(map[string]int)
+	r := &TenantRegistry{
+		cfg:     &tenantConfig{MaxPools: maxPools},
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		loading: make(map[string]*tenantCall),
+		stop:    make(chan struct{}),
+
+		closeGroup: func(g *Group) error {
+			closed[g.name]++
+			return nil
+		},
+	}
+	for _, id := range ids {
+		r.entries[id] = r.lru.PushFront(&tenantEntry{id: id, group: &Group{name: TenantGroupPrefix + id}})
+	}
+	return r, closed
+}
+
+func TestTenantRefs(t *testing.T) {
+	r, closed := testRegistry(2, "acme", "beta")
+	name := TenantGroupPrefix + "acme"
+	g, release, err := r.Acquire("acme")
+	if err != nil || g.name != TenantGroupPrefix + "acme" {
+		t.Fatalf("acquire: %v, %v", g, err)
+	}
+	_, release2, _ := r.Acquire("acme")
+	e := r.entries["acme"].Value.(*tenantEntry)
+	if e.refs != 2 {
+		t.Fatalf("refs = %d, want 2", e.refs)
+	}
+
+	// 淘汰仍在使用的数据组时不关闭，最后一个使用者释放后关闭
+	r.mu.Lock()
+	r.remove(r.entries["acme"])
+	r.mu.Unlock()
+	release()
+	if closed[name] != 0 {
+		t.Fatal("closed while still in use")
+	}
+	release()
+	if closed[name] != 0 {
+		t.Fatal("release is not idempotent")
+	}
+	release2()
+	if closed[name] != 1 {
+		t.Fatalf("closed %d times after last release, want 1", closed[name])
+	}
+	if closed[TenantGroupPrefix + "beta"] != 0 {
+		t.Fatal("closed a group that was not evicted")
+	}
+	if _, ok := r.entries["acme"]; ok {
+		t.Fatal("evicted entry is still registered")
+	}
+}
+
+func TestTenantCloseInUse(t *testing.T) {
+	r, closed := testRegistry(2, "acme", "beta")
+	name := TenantGroupPrefix + "acme"
+	_, release, _ := r.Acquire("acme")
+	r.Close()
+	if closed[name] != 0 {
+		t.Fatal("registry closed a group in use")
+	}
+	if closed[TenantGroupPrefix + "beta"] != 1 {
+		t.Fatal("registry did not close an idle group")
+	}
+	if _, _, err := r.Acquire("acme"); err != ErrTenantClosed {
+		t.Fatalf("acquire after close: %v", err)
+	}
+	release()
+	if closed[name] != 1 {
+		t.Fatalf("closed %d times after release, want 1", closed[name])
+	}
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"fmt"
+	"time"
+	"errors"
+	"context"
+	"strings"
+	"math/rand"
+	"database/sql"
+	"github.com/go-xorm/xorm"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinycoo/jinygo/log"
+)
+
+const DefaultTxRetries = 3
+
+var ErrIsolation = errors.New("db: isolation level is not supported per transaction by this adapter")
+
+type (
+	// Session 事务会话，Context 返回携带该事务的上下文，传给 Tx 时开启保存点，传给 UseContext 时返回同一事务会话
+	Session struct {
+		*xorm.Session
+		ctx context.Context
+	}
+
+	// TxOption Isolation 为事务隔离级别，Retries 为死锁或序列化失败时的重试次数，默认 3 次，-1 不重试
+	TxOption struct {
+		Isolation sql.IsolationLevel
+		Retries   int
+	}
+
+	txKey struct{}
+
+	// txIsolationKey 经由 session 的上下文将隔离级别传递至 logConn.BeginTx
+	txIsolationKey struct{}
+
+	txState struct {
+		dbName  string
+		session *xorm.Session
+		depth   int
+	}
+)
+
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+// Tx 在事务中执行 fn，fn 返回 nil 时提交，返回错误或 panic 时回滚
+// ctx 中已存在同一数据组的事务时使用保存点，仅最外层事务在死锁或序列化失败时重试
+func Tx(ctx context.Context, dbName string, fn func(s *Session) error, opts ...TxOption) error {
+	g, ok := DataGroup[dbName]
+	if !ok {
+		return errors.New(dbName + " - Database does not exist.")
+	}
+	return g.Tx(ctx, fn, opts...)
+}
+
+// Tx 在该数据组的事务中执行 fn，规则同 db.Tx
+func (g *Group) Tx(ctx context.Context, fn func(s *Session) error, opts ...TxOption) error {
+	var opt TxOption
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if state := txFrom(ctx, g.name); state != nil {
+		return savepoint(ctx, state, fn)
+	}
+	retries := opt.Retries
+	if retries == 0 {
+		retries = DefaultTxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		err := g.tx(ctx, opt.Isolation, fn)
+		if err == nil || attempt >= retries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		log.Warn(fmt.Sprintf("%s 事务冲突，第 %d 次重试：%v", g.name, attempt + 1, err))
+		backoff := time.Duration(10 << uint(attempt)) * time.Millisecond
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2) + 1)))
+	}
+}
+
+func (g *Group) tx(ctx context.Context, isolation sql.IsolationLevel, fn func(s *Session) error) (err error) {
+	session := g.Engine.NewSession()
+	defer session.Close()
+	if isolation != sql.LevelDefault && g.driver() == AdapterMySQL {
+		// mysql 须在开启事务前于同一连接上设置隔离级别，由驱动层 BeginTx 完成
+		session.Context(context.WithValue(ctx, txIsolationKey{}, isolation))
+	} else {
+		session.Context(ctx)
+	}
+	if err = session.Begin(); err != nil {
+		return err
+	}
+	if err = g.setIsolation(session, isolation); err != nil {
+		session.Rollback()
+		return err
+	}
+	state := &txState{dbName: g.name, session: session}
+	defer func() {
+		if r := recover(); r != nil {
+			g.resetIsolation(session, isolation)
+			session.Rollback()
+			panic(r)
+		}
+	}()
+	err = fn(&Session{Session: session, ctx: context.WithValue(ctx, txKey{}, state)})
+	g.resetIsolation(session, isolation)
+	if err != nil {
+		if rbErr := session.Rollback(); rbErr != nil {
+			log.Error(g.name + " 事务回滚失败：" + rbErr.Error())
+		}
+		return err
+	}
+	return session.Commit()
+}
+
+func savepoint(ctx context.Context, state *txState, fn func(s *Session) error) (err error) {
+	state.depth++
+	name := fmt.Sprintf("jinygo_sp_%d", state.depth)
+	defer func() { state.depth-- }()
+	if _, err = state.session.Exec("SAVEPOINT " + name); err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			state.session.Exec("ROLLBACK TO SAVEPOINT " + name)
+			panic(r)
+		}
+	}()
+	if err = fn(&Session{Session: state.session, ctx: ctx}); err != nil {
+		if _, rbErr := state.session.Exec("ROLLBACK TO SAVEPOINT " + name); rbErr != nil {
+			log.Error(state.dbName + " 保存点回滚失败：" + rbErr.Error())
+		}
+		return err
+	}
+	_, err = state.session.Exec("RELEASE SAVEPOINT " + name)
+	return err
+}
+
+func (g *Group) driver() string {
+	if g.adapter == nil {
+		return AdapterMySQL
+	}
+	return g.adapter.driver
+}
+
+// setIsolation 在事务开启后设置隔离级别，mysql 已在开启事务时设置
+func (g *Group) setIsolation(session *xorm.Session, isolation sql.IsolationLevel) error {
+	if isolation == sql.LevelDefault {
+		return nil
+	}
+	switch g.driver() {
+	case AdapterMySQL:
+		return nil
+	case AdapterPostgres:
+		level := strings.ToUpper(isolation.String())
+		if isolation == sql.LevelSnapshot || isolation == sql.LevelLinearizable || isolation == sql.LevelWriteCommitted {
+			return ErrIsolation
+		}
+		_, err := session.Exec("SET TRANSACTION ISOLATION LEVEL " + level)
+		return err
+	case AdapterSQLite:
+		// sqlite 事务始终为串行化，仅支持读未提交的开关
+		if isolation == sql.LevelReadUncommitted {
+			_, err := session.Exec("PRAGMA read_uncommitted = 1")
+			return err
+		}
+		return nil
+	}
+	return ErrIsolation
+}
+
+// resetIsolation 在提交或回滚前恢复连接级别的设置，避免影响连接池中后续使用该连接的请求
+func (g *Group) resetIsolation(session *xorm.Session, isolation sql.IsolationLevel) {
+	if isolation != sql.LevelReadUncommitted || g.driver() != AdapterSQLite {
+		return
+	}
+	if _, err := session.Exec("PRAGMA read_uncommitted = 0"); err != nil {
+		log.Warn(g.name + " 恢复 read_uncommitted 失败：" + err.Error())
+	}
+}
+
+func txFrom(ctx context.Context, dbName string) *txState {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.dbName == dbName {
+		return state
+	}
+	return nil
+}
+
+// retryable 判断是否为死锁、锁等待超时或序列化失败
+func retryable(err error) bool {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return me.Number == 1213 || me.Number == 1205
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"40001", "40p01", "deadlock", "could not serialize", "database is locked"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
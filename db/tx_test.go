@@ -0,0 +1,70 @@
+package db
+
+import (
+	"fmt"
+	"errors"
+	"context"
+	"testing"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/go-sql-driver/mysql"
+)
+
+type isolationConn struct {
+	driver.Conn
+	opts driver.TxOptions
+}
+
+func (c *isolationConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.opts = opts
+	return nil, nil
+}
+
+func TestBeginTxIsolation(t *testing.T) {
+	cases := []struct {
+		ctx  context.Context
+		want driver.IsolationLevel
+	}{
+		{context.Background(), 0},
+		{context.WithValue(context.Background(), txIsolationKey{}, sql.LevelSerializable), driver.IsolationLevel(sql.LevelSerializable)},
+		{context.WithValue(context.Background(), txIsolationKey{}, sql.LevelReadCommitted), driver.IsolationLevel(sql.LevelReadCommitted)},
+	}
+	for _, c := range cases {
+		conn := &isolationConn{}
+		lc := &logConn{Conn: conn, logger: &sqlLogger{}}
+		lc.BeginTx(c.ctx, driver.TxOptions{})
+		if conn.opts.Isolation != c.want {
+			t.Errorf("isolation = %d, want %d", conn.opts.Isolation, c.want)
+		}
+	}
+}
+
+func TestGroupDriver(t *testing.T) {
+	if d := (&Group{}).driver(); d != AdapterMySQL {
+		t.Errorf("default driver = %s", d)
+	}
+	if d := (&Group{adapter: adapters[AdapterSQLite]}).driver(); d != AdapterSQLite {
+		t.Errorf("sqlite driver = %s", d)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, true},
+		{fmt.Errorf("update: %w", &mysql.MySQLError{Number: 1205}), true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{errors.New("Error 1213: Deadlock found when trying to get lock"), true},
+		{errors.New("pq: could not serialize access due to concurrent update"), true},
+		{errors.New("ERROR: deadlock detected (SQLSTATE 40P01)"), true},
+		{errors.New("database is locked"), true},
+		{errors.New("no such table"), false},
+	}
+	for _, c := range cases {
+		if got := retryable(c.err); got != c.want {
+			t.Errorf("retryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
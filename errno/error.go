@@ -1,5 +1,32 @@
 package errno
 
+const (
+	ErrUnauthorized = 401
+	ErrForbidden    = 403
+	ErrInternal = 500
+	ErrBadGateway = 502
+	ErrTimeout  = 504
+
+	ErrSignMissing   = 40101
+	ErrSignAppKey    = 40102
+	ErrSignTimestamp = 40103
+	ErrSignReplay    = 40104
+	ErrSignInvalid   = 40105
+	ErrSignTooLarge  = 41301
+	ErrSignNonce     = 50302
+
+	ErrIdemMissing    = 40001
+	ErrIdemProcessing = 40901
+	ErrIdemMismatch   = 42201
+	ErrIdemTooLarge   = 41302
+
+	ErrPageCursor = 40003
+
+	ErrTenantMissing     = 40002
+	ErrTenantNotFound    = 40401
+	ErrTenantUnavailable = 50301
+)
+
 var ErrCode map[int]string
 
 func init() {
@@ -8,8 +35,32 @@ func init() {
 	ErrCode[1] = "欢迎访问JinyGo！"
 
 	ErrCode[200] = "请求成功"
+	ErrCode[ErrUnauthorized] = "请先登录"
+	ErrCode[ErrForbidden] = "没有访问权限"
 	ErrCode[404] = "请求地址不存在"
+	ErrCode[ErrInternal] = "服务器内部错误"
+	ErrCode[ErrBadGateway] = "上游服务不可用"
+	ErrCode[ErrTimeout] = "请求处理超时"
 
 	ErrCode[20000] = "请求参数有误，具体请参考接口文档"
 	ErrCode[20001] = "所需参数缺失，具体请参考接口文档"
+
+	ErrCode[ErrSignMissing] = "签名参数缺失"
+	ErrCode[ErrSignAppKey] = "app key 无效"
+	ErrCode[ErrSignTimestamp] = "请求时间戳无效或已过期"
+	ErrCode[ErrSignReplay] = "请求重复提交"
+	ErrCode[ErrSignInvalid] = "签名校验失败"
+	ErrCode[ErrSignTooLarge] = "请求体过大"
+	ErrCode[ErrSignNonce] = "nonce 校验暂不可用"
+
+	ErrCode[ErrIdemMissing] = "缺少 Idempotency-Key 请求头"
+	ErrCode[ErrIdemProcessing] = "相同 Idempotency-Key 的请求正在处理中"
+	ErrCode[ErrIdemMismatch] = "Idempotency-Key 已被不同的请求内容使用"
+	ErrCode[ErrIdemTooLarge] = "请求体过大"
+
+	ErrCode[ErrPageCursor] = "分页游标无效"
+
+	ErrCode[ErrTenantMissing] = "缺少租户信息"
+	ErrCode[ErrTenantNotFound] = "租户不存在"
+	ErrCode[ErrTenantUnavailable] = "租户数据库暂不可用"
 }
\ No newline at end of file
@@ -0,0 +1,309 @@
+package gen
+
+import (
+	"os"
+	"fmt"
+	"sort"
+	"bytes"
+	"errors"
+	"strings"
+	"strconv"
+	"go/token"
+	"go/format"
+	"reflect"
+	"io/ioutil"
+	"text/template"
+	"path/filepath"
+	"github.com/go-xorm/core"
+	"github.com/jinycoo/jinygo/db"
+	"github.com/jinycoo/jinygo/utils"
+)
+
+const (
+	JsonSnake = "snake"
+	JsonCamel = "camel"
+
+	ModelTemplate = "model.tpl"
+	DaoTemplate   = "dao.tpl"
+
+	dbImport = "github.com/jinycoo/jinygo/db"
+)
+
+type (
+	// ModelOption Tables 为空时生成全部表，Prefix 为生成结构体名时去除的表前缀
+	// Templates 目录下的 model.tpl、dao.tpl 覆盖默认模板，Json 为 json 标签风格 snake 或 camel
+	ModelOption struct {
+		DbName    string
+		Tables    []string
+		Prefix    string
+		Output    string
+		Package   string
+		Templates string
+		Dao       bool
+		Json      string
+	}
+
+	// Model 模板数据，Pk 为单列主键，联合主键或无主键时为 nil
+	// Imports 为模型文件需导入的包，DaoImports 为 DAO 文件需导入的包
+	Model struct {
+		Package    string
+		DbName     string
+		Table      string
+		Name       string
+		Comment    string
+		Imports    []string
+		DaoImports []string
+		Fields     []*Field
+		Pk         *Field
+	}
+
+	// Field 模板数据，Tag 为完整的结构体标签，Import 为类型所在的包，内置类型为空
+	Field struct {
+		Name     string
+		Type     string
+		Import   string
+		Column   string
+		Json     string
+		Tag      string
+		Comment  string
+		Pk       bool
+		AutoIncr bool
+	}
+)
+
+// Models 读取数据组主库的表结构，生成 xorm 模型及 DAO 文件，返回生成的文件
+func Models(opt ModelOption) ([]string, error) {
+	engine := db.Master(opt.DbName)
+	if engine == nil {
+		return nil, errors.New(opt.DbName + " - Database does not exist.")
+	}
+	tables, err := engine.DBMetas()
+	if err != nil {
+		return nil, err
+	}
+	if opt.Output == "" {
+		opt.Output = "models"
+	}
+	if opt.Package == "" {
+		opt.Package = filepath.Base(opt.Output)
+	}
+	modelTpl, err := loadTemplate(opt.Templates, ModelTemplate, defaultModelTemplate)
+	if err != nil {
+		return nil, err
+	}
+	daoTpl, err := loadTemplate(opt.Templates, DaoTemplate, defaultDaoTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(opt.Output, 0755); err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(opt.Tables))
+	for _, t := range opt.Tables {
+		wanted[t] = true
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	files := make([]string, 0)
+	structs := make(map[string]string)
+	for _, table := range tables {
+		if len(wanted) > 0 && !wanted[table.Name] {
+			continue
+		}
+		delete(wanted, table.Name)
+		m, err := newModel(table, opt)
+		if err != nil {
+			return files, err
+		}
+		if t, ok := structs[m.Name]; ok {
+			return files, fmt.Errorf("表 %s 与 %s 生成相同的结构体名 %s", t, table.Name, m.Name)
+		}
+		structs[m.Name] = table.Name
+		// 统一添加后缀，避免 _test、_linux 等表名被识别为测试文件或构建约束
+		file := filepath.Join(opt.Output, table.Name + "_model.go")
+		if err = render(modelTpl, m, file); err != nil {
+			return files, err
+		}
+		files = append(files, file)
+		if opt.Dao {
+			file = filepath.Join(opt.Output, table.Name + "_dao.go")
+			if err = render(daoTpl, m, file); err != nil {
+				return files, err
+			}
+			files = append(files, file)
+		}
+	}
+	for t := range wanted {
+		return files, errors.New(t + " - Table does not exist.")
+	}
+	return files, nil
+}
+
+// newModel 由表结构生成模板数据，列名转换后的字段名重复或与 TableName 方法同名时返回错误
+func newModel(table *core.Table, opt ModelOption) (*Model, error) {
+	m := &Model{
+		Package: opt.Package,
+		DbName:  opt.DbName,
+		Table:   table.Name,
+		Name:    goName(strings.TrimPrefix(table.Name, opt.Prefix)),
+		Comment: table.Comment,
+	}
+	imports := make(map[string]bool)
+	names := map[string]string{"TableName": ""}
+	for _, col := range table.Columns() {
+		f := &Field{
+			Name:     goName(col.Name),
+			Column:   col.Name,
+			Comment:  strings.Replace(col.Comment, "\n", " ", -1),
+			Pk:       col.IsPrimaryKey,
+			AutoIncr: col.IsAutoIncrement,
+		}
+		if c, ok := names[f.Name]; ok {
+			if c == "" {
+				return nil, fmt.Errorf("%s 表的列 %s 生成的字段名 %s 与 TableName 方法冲突", table.Name, col.Name, f.Name)
+			}
+			return nil, fmt.Errorf("%s 表的列 %s 与 %s 生成相同的字段名 %s", table.Name, c, col.Name, f.Name)
+		}
+		names[f.Name] = col.Name
+		f.Type, f.Import = goType(col)
+		if opt.Json == JsonCamel {
+			f.Json = utils.CamelStrings(col.Name)
+		} else {
+			f.Json = utils.SnakeString(f.Name)
+		}
+		f.Tag = "`xorm:\"" + xormTag(col) + "\" json:\"" + f.Json + "\"`"
+		if f.Import != "" {
+			imports[f.Import] = true
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	m.DaoImports = []string{"context"}
+	if len(table.PrimaryKeys) == 1 {
+		for _, f := range m.Fields {
+			if f.Pk {
+				m.Pk = f
+			}
+		}
+		if m.Pk.Import != "" {
+			m.DaoImports = append(m.DaoImports, m.Pk.Import)
+		}
+	}
+	m.DaoImports = append(m.DaoImports, dbImport)
+	for imp := range imports {
+		m.Imports = append(m.Imports, imp)
+	}
+	sort.Strings(m.Imports)
+	return m, nil
+}
+
+// goName 按 utils.CamelStrings 规则生成导出名称，例如 user_name 为 UserName
+func goName(name string) string {
+	if name == strings.ToUpper(name) {
+		name = strings.ToLower(name)
+	}
+	return utils.Ucfirst(utils.CamelStrings(name))
+}
+
+// goType 返回列对应的 Go 类型及其所在的包，内置类型的包为空
+func goType(col *core.Column) (string, string) {
+	t := core.SQLType2Type(col.SQLType)
+	if t == nil {
+		return "string", ""
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return "[]byte", ""
+	}
+	name := t.String()
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return name, t.PkgPath()
+}
+
+// xormTag 生成 xorm 标签，created_at、updated_at、deleted_at 列分别标记为 created、updated、deleted
+func xormTag(col *core.Column) string {
+	tags := []string{"'" + col.Name + "'"}
+	typ := strings.ToUpper(col.SQLType.Name)
+	if col.Length > 0 {
+		typ += "(" + strconv.Itoa(col.Length)
+		if col.Length2 > 0 {
+			typ += "," + strconv.Itoa(col.Length2)
+		}
+		typ += ")"
+	}
+	tags = append(tags, typ)
+	if col.IsPrimaryKey {
+		tags = append(tags, "pk")
+	}
+	if col.IsAutoIncrement {
+		tags = append(tags, "autoincr")
+	}
+	if !col.Nullable && !col.IsPrimaryKey {
+		tags = append(tags, "notnull")
+	}
+	names := make([]string, 0, len(col.Indexes))
+	for name := range col.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if col.Indexes[name] == core.UniqueType {
+			tags = append(tags, "unique(" + name + ")")
+		} else {
+			tags = append(tags, "index(" + name + ")")
+		}
+	}
+	switch strings.ToLower(col.Name) {
+	case "created_at":
+		tags = append(tags, "created")
+	case "updated_at":
+		tags = append(tags, "updated")
+	case "deleted_at":
+		tags = append(tags, "deleted")
+	}
+	return strings.Join(tags, " ")
+}
+
+func loadTemplate(dir, name, def string) (*template.Template, error) {
+	text := def
+	if dir != "" {
+		buf, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			text = string(buf)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return template.New(name).Funcs(template.FuncMap{
+		"camel": utils.CamelStrings,
+		"snake": utils.SnakeString,
+		"ucfirst": utils.Ucfirst,
+		"lcfirst": utils.Lcfirst,
+		"param": param,
+	}).Parse(text)
+}
+
+// param 由字段名生成参数名，与关键字冲突时添加下划线后缀
+func param(name string) string {
+	p := utils.Lcfirst(name)
+	if token.Lookup(p).IsKeyword() {
+		p += "_"
+	}
+	return p
+}
+
+// render 执行模板并格式化后写入文件
+func render(tpl *template.Template, m *Model, file string) error {
+	src, err := execute(tpl, m)
+	if err != nil {
+		return errors.New(file + " 生成失败：" + err.Error())
+	}
+	return ioutil.WriteFile(file, src, 0644)
+}
+
+func execute(tpl *template.Template, m *Model) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, m); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
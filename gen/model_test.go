@@ -0,0 +1,97 @@
+package gen
+
+import (
+	"flag"
+	"bytes"
+	"strings"
+	"testing"
+	"io/ioutil"
+	"text/template"
+	"path/filepath"
+	"github.com/go-xorm/core"
+)
+
+var update = flag.Bool("update", false, "更新 testdata 下的 golden 文件")
+
+func column(name, typ string, length int, nullable bool) *core.Column {
+	return core.NewColumn(name, "", core.SQLType{Name: typ}, length, 0, nullable)
+}
+
+func testTables() []*core.Table {
+	user := core.NewEmptyTable()
+	user.Name = "jiny_user"
+	user.Comment = "用户"
+	id := column("id", core.BigInt, 20, false)
+	id.IsPrimaryKey, id.IsAutoIncrement = true, true
+	name := column("user_name", core.Varchar, 64, false)
+	name.Comment = "用户名"
+	name.Indexes["uk_name"] = core.UniqueType
+	user.AddColumn(id)
+	user.AddColumn(name)
+	user.AddColumn(column("avatar", core.Blob, 0, true))
+	user.AddColumn(column("created_at", core.DateTime, 0, true))
+
+	stat := core.NewEmptyTable()
+	stat.Name = "daily_stat"
+	day := column("day", core.Date, 0, false)
+	day.IsPrimaryKey = true
+	stat.AddColumn(day)
+	stat.AddColumn(column("pv", core.BigInt, 20, false))
+	return []*core.Table{user, stat}
+}
+
+func TestGolden(t *testing.T) {
+	opt := ModelOption{DbName: "jiny_db", Prefix: "jiny_", Package: "models"}
+	modelTpl, err := loadTemplate("", ModelTemplate, defaultModelTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daoTpl, err := loadTemplate("", DaoTemplate, defaultDaoTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range testTables() {
+		m, err := newModel(table, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range []struct {
+			suffix string
+			tpl    *template.Template
+		}{{"_model", modelTpl}, {"_dao", daoTpl}} {
+			suffix := g.suffix
+			got, err := execute(g.tpl, m)
+			if err != nil {
+				t.Fatalf("%s%s: %v", table.Name, suffix, err)
+			}
+			golden := filepath.Join("testdata", table.Name + suffix + ".golden")
+			if *update {
+				if err = ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s 与生成结果不一致：\n%s", golden, got)
+			}
+		}
+	}
+}
+
+func TestNameCollision(t *testing.T) {
+	for _, cols := range [][]string{{"user_id", "userId"}, {"id", "table_name"}} {
+		table := core.NewEmptyTable()
+		table.Name = "t"
+		for _, c := range cols {
+			table.AddColumn(column(c, core.Int, 11, false))
+		}
+		_, err := newModel(table, ModelOption{})
+		if err == nil || !strings.Contains(err.Error(), cols[len(cols)-1]) {
+			t.Errorf("%v: err = %v", cols, err)
+		}
+	}
+}
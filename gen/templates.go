@@ -0,0 +1,96 @@
+package gen
+
+// defaultModelTemplate 默认模型模板，可在 ModelOption.Templates 目录下以 model.tpl 覆盖
+const defaultModelTemplate = `// Code generated by jinygo gen model. DO NOT EDIT.
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{end}}
+// {{.Name}} {{if .Comment}}{{.Comment}}{{else}}{{.Table}} 表{{end}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}} {{.Tag}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+
+// TableName 返回表名
+func (m *{{.Name}}) TableName() string {
+	return "{{.Table}}"
+}
+`
+
+// defaultDaoTemplate 默认 DAO 模板，读操作经 db.UseContext 读写分离，写操作使用主库，ctx 携带事务时使用该事务
+const defaultDaoTemplate = `// Code generated by jinygo gen model. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .DaoImports}}
+	"{{.}}"
+{{- end}}
+)
+
+// {{.Name}}Dao {{.Table}} 表的数据访问
+type {{.Name}}Dao struct {
+	dbName string
+}
+
+// New{{.Name}}Dao dbName 为空时使用 {{.DbName}}
+func New{{.Name}}Dao(dbName string) *{{.Name}}Dao {
+	if dbName == "" {
+		dbName = "{{.DbName}}"
+	}
+	return &{{.Name}}Dao{dbName: dbName}
+}
+{{with .Pk}}
+// Get 按主键查询，记录不存在时返回 nil
+func (d *{{$.Name}}Dao) Get(ctx context.Context, {{param .Name}} {{.Type}}) (*{{$.Name}}, error) {
+	m := new({{$.Name}})
+	has, err := db.UseContext(ctx, d.dbName).ID({{param .Name}}).Get(m)
+	if err != nil || !has {
+		return nil, err
+	}
+	return m, nil
+}
+{{end}}
+// Find 按 cond 的非零字段查询，limit 为 0 时不限制条数
+func (d *{{.Name}}Dao) Find(ctx context.Context, cond *{{.Name}}, limit, start int) ([]*{{.Name}}, error) {
+	list := make([]*{{.Name}}, 0)
+	sess := db.UseContext(ctx, d.dbName)
+	if limit > 0 {
+		sess = sess.Limit(limit, start)
+	}
+	err := sess.Find(&list, cond)
+	return list, err
+}
+
+// Count 按 cond 的非零字段统计
+func (d *{{.Name}}Dao) Count(ctx context.Context, cond *{{.Name}}) (int64, error) {
+	return db.UseContext(ctx, d.dbName).Count(cond)
+}
+
+// Insert 写入记录，自增主键回填至 m
+func (d *{{.Name}}Dao) Insert(ctx context.Context, m *{{.Name}}) error {
+	_, err := db.Use(d.dbName).Write(ctx).Insert(m)
+	return err
+}
+{{with .Pk}}
+// Update 按主键更新，cols 为空时更新非零字段
+func (d *{{$.Name}}Dao) Update(ctx context.Context, m *{{$.Name}}, cols ...string) (int64, error) {
+	sess := db.Use(d.dbName).Write(ctx).ID(m.{{.Name}})
+	if len(cols) > 0 {
+		sess = sess.Cols(cols...)
+	}
+	return sess.Update(m)
+}
+
+// Delete 按主键删除
+func (d *{{$.Name}}Dao) Delete(ctx context.Context, {{param .Name}} {{.Type}}) (int64, error) {
+	return db.Use(d.dbName).Write(ctx).ID({{param .Name}}).Delete(new({{$.Name}}))
+}
+{{end}}`
@@ -1,11 +1,35 @@
 module github.com/jinycoo/jinygo
 
+go 1.17
+
+require (
+	github.com/alicebob/miniredis v2.5.0+incompatible
+	github.com/gin-contrib/sse v0.0.0-20170109093832-22d885f9ecc7
+	github.com/gin-gonic/gin v1.3.0
+	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/go-xorm/core v0.6.2
+	github.com/go-xorm/xorm v0.7.3
+	github.com/golang/protobuf v1.2.0
+	github.com/gomodule/redigo v1.7.0
+	github.com/gorilla/websocket v1.4.1
+	github.com/json-iterator/go v1.1.12
+	github.com/lib/pq v1.10.0
+	github.com/mattn/go-isatty v0.0.4
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/streadway/amqp v0.0.0-20180528204448-e5adc2ada8b8
+	github.com/ugorji/go/codec v0.0.0-20181204163529-d75b2dcb6bc8
+	go.uber.org/zap v1.11.0
+	gopkg.in/go-playground/validator.v8 v8.18.2
+	gopkg.in/yaml.v2 v2.2.2
+)
+
 require (
-	github.com/gin-contrib/sse v0.0.0-20170109093832-22d885f9ecc7 // indirect
-	github.com/gin-gonic/gin v1.3.0 // indirect
-	github.com/golang/protobuf v1.2.0 // indirect
-	github.com/mattn/go-isatty v0.0.4 // indirect
-	github.com/ugorji/go/codec v0.0.0-20181204163529-d75b2dcb6bc8 // indirect
-	gopkg.in/go-playground/validator.v8 v8.18.2 // indirect
-	gopkg.in/yaml.v2 v2.2.2 // indirect
+	github.com/alicebob/gopher-json v0.0.0-20180125190556-5a6b3ba71ee6 // indirect
+	github.com/go-xorm/builder v0.3.3 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/yuin/gopher-lua v0.0.0-20190514113301-1cd887cd7036 // indirect
+	go.uber.org/atomic v1.5.1 // indirect
+	go.uber.org/multierr v1.2.0 // indirect
 )
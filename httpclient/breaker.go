@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	stateClosed = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// breaker 连续失败计数熔断器，冷却结束后仅放行一个探测请求，成功则恢复
+type breaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     int
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+// allow 报告是否放行请求，probe 为 true 时该请求为半开状态的探测请求
+func (b *breaker) allow() (allowed, probe bool) {
+	if b.threshold <= 0 {
+		return true, false
+	}
+	b.Lock()
+	defer b.Unlock()
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false, false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		return true, true
+	case stateHalfOpen:
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	}
+	return true, false
+}
+
+// done 记录请求结果，熔断前发出的请求在熔断后完成时不影响熔断状态
+func (b *breaker) done(probe, success bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	if probe {
+		b.probing = false
+	} else if b.state != stateClosed {
+		return
+	}
+	if success {
+		b.state = stateClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.state = stateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) open() bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.state == stateOpen
+}
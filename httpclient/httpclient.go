@@ -0,0 +1,443 @@
+package httpclient
+
+import (
+	"io"
+	"fmt"
+	"net"
+	"time"
+	"bytes"
+	"errors"
+	"context"
+	"strings"
+	"net/http"
+	"io/ioutil"
+	"math/rand"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
+)
+
+const (
+	HeaderRequestId = "X-Request-Id"
+	HeaderIdempotencyKey = "Idempotency-Key"
+
+	DefaultTimeout     = 10 * time.Second
+	DefaultDialTimeout = 3 * time.Second
+	DefaultBackoff     = 100 * time.Millisecond
+	DefaultMaxBackoff  = 2 * time.Second
+	DefaultCooldown    = 30 * time.Second
+
+	logBodyLimit = 1024
+)
+
+var (
+	ErrCircuitOpen = errors.New("httpclient: circuit breaker is open")
+
+	httpCfg *HttpConfig
+	clients = make(map[string]*Client)
+)
+
+type (
+	HttpConfig struct {
+		Upstreams map[string]*UpstreamConfig `yaml:"upstreams"`
+	}
+
+	// UpstreamConfig 上游服务配置，Timeout 为单次请求（含重试前的每次尝试）超时时间，包括读取响应体的时间
+	UpstreamConfig struct {
+		BaseUrl      string            `yaml:"baseUrl"`
+		Timeout      time.Duration     `yaml:"timeout"`
+		DialTimeout  time.Duration     `yaml:"dialTimeout"`
+		MaxIdleConns int               `yaml:"maxIdleConns"`
+		Headers      map[string]string `yaml:"headers"`
+		LogBody      bool              `yaml:"logBody"`
+		Retry        RetryConfig       `yaml:"retry"`
+		Breaker      BreakerConfig     `yaml:"breaker"`
+	}
+
+	// RetryConfig 重试配置，仅幂等方法或带 Idempotency-Key 的请求会被重试
+	RetryConfig struct {
+		Max        int           `yaml:"max"`
+		Backoff    time.Duration `yaml:"backoff"`
+		MaxBackoff time.Duration `yaml:"maxBackoff"`
+	}
+
+	// BreakerConfig 熔断配置，连续失败 Threshold 次后熔断 Cooldown 时长，Threshold 为 0 时不启用
+	BreakerConfig struct {
+		Threshold int           `yaml:"threshold"`
+		Cooldown  time.Duration `yaml:"cooldown"`
+	}
+
+	Client struct {
+		name    string
+		cfg     *UpstreamConfig
+		client  *http.Client
+		breaker *breaker
+		stats   *stats
+	}
+
+	// StatusError 非 2xx 响应
+	StatusError struct {
+		StatusCode int
+		Body       []byte
+	}
+
+	// peekedBody 已读取开头用于日志的请求体或响应体，读取时先返回已读取的部分
+	peekedBody struct {
+		io.Reader
+		io.Closer
+	}
+
+	// cancelBody 关闭响应体时取消本次请求的上下文
+	cancelBody struct {
+		io.ReadCloser
+		cancel context.CancelFunc
+	}
+)
+
+func (e *StatusError) Error() string {
+	return "httpclient: unexpected status " + http.StatusText(e.StatusCode) + ": " + string(truncate(e.Body))
+}
+
+func Init(cfgFile string) error {
+	buf, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("%s文件读取失败：%v", cfgFile, err)
+	}
+	err = secret.Unmarshal(buf, &httpCfg)
+	if err != nil {
+		return fmt.Errorf("%s解析失败：%v", cfgFile, err)
+	}
+	InitClients(httpCfg)
+	return nil
+}
+
+func InitClients(cfg *HttpConfig) {
+	if cfg == nil {
+		log.Error("httpclient config setting error")
+		return
+	}
+	for name, upstream := range cfg.Upstreams {
+		Register(name, New(name, upstream))
+	}
+}
+
+// New 按上游配置创建客户端
+func New(name string, cfg *UpstreamConfig) *Client {
+	if cfg == nil {
+		cfg = &UpstreamConfig{}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = DefaultDialTimeout
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = 100
+	}
+	if cfg.Retry.Backoff <= 0 {
+		cfg.Retry.Backoff = DefaultBackoff
+	}
+	if cfg.Retry.MaxBackoff <= 0 {
+		cfg.Retry.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Breaker.Cooldown <= 0 {
+		cfg.Breaker.Cooldown = DefaultCooldown
+	}
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConns,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: cfg.DialTimeout,
+	}
+	return &Client{
+		name:    name,
+		cfg:     cfg,
+		client:  &http.Client{Transport: transport},
+		breaker: &breaker{threshold: cfg.Breaker.Threshold, cooldown: cfg.Breaker.Cooldown},
+		stats:   &stats{},
+	}
+}
+
+func Register(name string, c *Client) {
+	clients[name] = c
+}
+
+func Use(name string) *Client {
+	if c, ok := clients[name]; ok {
+		return c
+	}
+	log.Error(name + " - Http upstream does not exist.")
+	return nil
+}
+
+// WithRequestId 将请求 ID 写入上下文，客户端发出请求时通过 X-Request-Id 传递给上游
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return log.WithRequestId(ctx, id)
+}
+
+// NewRequest 创建相对于 BaseUrl 的请求
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	url := path
+	if c.cfg.BaseUrl != "" && !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = strings.TrimRight(c.cfg.BaseUrl, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+// Do 发送请求，按配置重试并记录日志及指标，熔断时返回 ErrCircuitOpen
+// 响应体不预先读取，调用方读取完毕后须关闭
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for k, v := range c.cfg.Headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+	requestId := req.Header.Get(HeaderRequestId)
+	if requestId == "" {
+		if requestId = log.RequestId(ctx); requestId != "" {
+			req.Header.Set(HeaderRequestId, requestId)
+		}
+	}
+
+	attempts := 1
+	if c.retryable(req) {
+		attempts += c.cfg.Retry.Max
+	}
+	var reqBody []byte
+	if req.Body != nil && req.GetBody == nil {
+		if attempts > 1 {
+			// 重试时需要重放请求体
+			reqBody, _ = ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			req.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(reqBody)), nil
+			}
+			req.Body, _ = req.GetBody()
+		} else if c.cfg.LogBody {
+			reqBody, req.Body, _ = peekBody(req.Body)
+		}
+	} else if c.cfg.LogBody && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = ioutil.ReadAll(io.LimitReader(body, logBodyLimit))
+			body.Close()
+		}
+	}
+
+	var (
+		res *http.Response
+		err error
+	)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if !c.sleep(ctx, attempt-1) {
+				break
+			}
+			if req.GetBody != nil {
+				req.Body, _ = req.GetBody()
+			}
+			c.stats.retry()
+		}
+		allowed, probe := c.breaker.allow()
+		if !allowed {
+			c.stats.reject()
+			err = ErrCircuitOpen
+			c.logRequest(req, requestId, attempt, nil, err, 0, reqBody)
+			return nil, err
+		}
+		res, err = c.attempt(req, requestId, attempt, reqBody)
+		failed := err != nil || res.StatusCode >= 500
+		c.breaker.done(probe, !failed)
+		if !failed || attempt == attempts || !retryStatus(res, err) || ctx.Err() != nil {
+			break
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+	}
+	return res, err
+}
+
+func (c *Client) attempt(req *http.Request, requestId string, attempt int, reqBody []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.cfg.Timeout)
+	start := time.Now()
+	res, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		c.stats.observe(0, time.Since(start))
+		c.logRequest(req, requestId, attempt, nil, err, time.Since(start), reqBody)
+		return nil, err
+	}
+	// 仅读取日志所需的响应体开头，其余部分由调用方读取，关闭响应体后再取消上下文
+	var resBody []byte
+	body := res.Body
+	if c.cfg.LogBody {
+		resBody, body, err = peekBody(res.Body)
+	}
+	duration := time.Since(start)
+	c.stats.observe(res.StatusCode, duration)
+	if err != nil {
+		body.Close()
+		cancel()
+		c.logRequest(req, requestId, attempt, res, err, duration, reqBody)
+		return nil, err
+	}
+	res.Body = &cancelBody{ReadCloser: body, cancel: cancel}
+	c.logRequest(req, requestId, attempt, res, nil, duration, reqBody, resBody...)
+	return res, nil
+}
+
+// peekBody 读取 body 开头至多 logBodyLimit 字节，返回的 body 仍可读取完整内容
+func peekBody(body io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	head := make([]byte, logBodyLimit)
+	n, err := io.ReadFull(body, head)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	head = head[:n]
+	return head, &peekedBody{Reader: io.MultiReader(bytes.NewReader(head), body), Closer: body}, err
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (c *Client) retryable(req *http.Request) bool {
+	if c.cfg.Retry.Max <= 0 {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get(HeaderIdempotencyKey) != ""
+}
+
+func retryStatus(res *http.Response, err error) bool {
+	if err != nil {
+		return err != ErrCircuitOpen
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff 第 retry 次重试前的等待时间，指数增长至 MaxBackoff 并加入随机抖动，取值范围为 [d/2, d]
+func (c *Client) backoff(retry int) time.Duration {
+	d := c.cfg.Retry.Backoff << uint(retry-1)
+	if d <= 0 || d > c.cfg.Retry.MaxBackoff {
+		d = c.cfg.Retry.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// sleep 按 backoff 等待，上下文取消时返回 false
+func (c *Client) sleep(ctx context.Context, retry int) bool {
+	timer := time.NewTimer(c.backoff(retry))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (c *Client) logRequest(req *http.Request, requestId string, attempt int, res *http.Response, err error, duration time.Duration, reqBody []byte, resBody ...byte) {
+	fields := map[string]interface{}{
+		"upstream":    c.name,
+		"method":      req.Method,
+		"url":         req.URL.String(),
+		"attempt":     attempt,
+		"duration_ms": duration.Nanoseconds() / int64(time.Millisecond),
+	}
+	if requestId != "" {
+		fields["request_id"] = requestId
+	}
+	if c.cfg.LogBody {
+		fields["request_body"] = string(truncate(reqBody))
+		fields["response_body"] = string(truncate(resBody))
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		log.CWarn("Http client request failed", fields)
+		return
+	}
+	fields["status"] = res.StatusCode
+	if res.StatusCode >= 500 {
+		log.CWarn("Http client request", fields)
+	} else {
+		log.CInfo("Http client request", fields)
+	}
+}
+
+func (c *Client) Get(ctx context.Context, path string, out interface{}) error {
+	return c.JSON(ctx, http.MethodGet, path, nil, out)
+}
+
+func (c *Client) Post(ctx context.Context, path string, in, out interface{}) error {
+	return c.JSON(ctx, http.MethodPost, path, in, out)
+}
+
+func (c *Client) Put(ctx context.Context, path string, in, out interface{}) error {
+	return c.JSON(ctx, http.MethodPut, path, in, out)
+}
+
+func (c *Client) Delete(ctx context.Context, path string, out interface{}) error {
+	return c.JSON(ctx, http.MethodDelete, path, nil, out)
+}
+
+// JSON 以 JSON 编码 in 发送请求，并将 2xx 响应解码至 out，非 2xx 响应返回 *StatusError
+func (c *Client) JSON(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		buf, err := jsoniter.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := c.NewRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode/100 != 2 {
+		return &StatusError{StatusCode: res.StatusCode, Body: buf}
+	}
+	if out == nil || len(buf) == 0 {
+		return nil
+	}
+	return jsoniter.Unmarshal(buf, out)
+}
+
+func truncate(b []byte) []byte {
+	if len(b) > logBodyLimit {
+		return b[:logBodyLimit]
+	}
+	return b
+}
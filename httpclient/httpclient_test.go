@@ -0,0 +1,179 @@
+package httpclient
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"context"
+	"net/http"
+	"io/ioutil"
+	"sync/atomic"
+	"net/http/httptest"
+	"github.com/jinycoo/jinygo/log"
+)
+
+// flaky 前 fails 次请求返回 503，并记录每次收到的请求体
+func flaky(fails int32) (*httptest.Server, *int32, chan string) {
+	var hits int32
+	bodies := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+		if atomic.AddInt32(&hits, 1) <= fails {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	return srv, &hits, bodies
+}
+
+func TestRetry(t *testing.T) {
+	log.New(nil)
+	cases := []struct {
+		method string
+		key    string
+		hits   int32
+		status int
+	}{
+		{http.MethodGet, "", 3, 200},
+		{http.MethodPost, "", 1, 503},
+		{http.MethodPost, "order-1", 3, 200},
+	}
+	for _, tc := range cases {
+		srv, hits, bodies := flaky(2)
+		c := New("retry", &UpstreamConfig{BaseUrl: srv.URL, Retry: RetryConfig{Max: 3, Backoff: time.Millisecond}})
+		req, _ := c.NewRequest(context.Background(), tc.method, "/orders", strings.NewReader("payload"))
+		if tc.key != "" {
+			req.Header.Set(HeaderIdempotencyKey, tc.key)
+		}
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s %q: %v", tc.method, tc.key, err)
+		}
+		res.Body.Close()
+		srv.Close()
+		if res.StatusCode != tc.status || *hits != tc.hits {
+			t.Errorf("%s %q: status %d after %d attempts, want %d after %d", tc.method, tc.key, res.StatusCode, *hits, tc.status, tc.hits)
+		}
+		close(bodies)
+		for body := range bodies {
+			if body != "payload" {
+				t.Errorf("%s %q: replayed body %q", tc.method, tc.key, body)
+			}
+		}
+		if s := c.Stats(); s.Retries != int64(tc.hits-1) {
+			t.Errorf("%s %q: retries = %d", tc.method, tc.key, s.Retries)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := New("backoff", &UpstreamConfig{Retry: RetryConfig{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}})
+	for retry, limit := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 10: 300, 80: 300} {
+		limit *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := c.backoff(retry); d < limit/2 || d > limit {
+				t.Fatalf("retry %d: backoff %s not in [%s, %s]", retry, d, limit/2, limit)
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c.sleep(ctx, 1) {
+		t.Error("sleep ignored canceled context")
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{threshold: 2, cooldown: 20 * time.Millisecond}
+	// 熔断前发出的请求
+	_, stale := b.allow()
+	b.done(false, false)
+	if b.open() {
+		t.Fatal("opened before threshold")
+	}
+	b.done(false, false)
+	if !b.open() {
+		t.Fatal("not opened after threshold")
+	}
+	if ok, _ := b.allow(); ok {
+		t.Fatal("allowed during cooldown")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	ok, probe := b.allow()
+	if !ok || !probe {
+		t.Fatalf("after cooldown: allowed %v, probe %v", ok, probe)
+	}
+	if ok, _ := b.allow(); ok {
+		t.Fatal("second request allowed while probing")
+	}
+	// 非探测请求完成时不清除探测状态
+	b.done(stale, true)
+	if ok, _ := b.allow(); ok || b.state != stateHalfOpen {
+		t.Fatal("stale request changed half-open state")
+	}
+	b.done(probe, false)
+	if !b.open() {
+		t.Fatal("failed probe did not reopen")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	_, probe = b.allow()
+	b.done(probe, true)
+	if b.state != stateClosed || b.failures != 0 {
+		t.Fatalf("successful probe: state %d, failures %d", b.state, b.failures)
+	}
+	if ok, probe := b.allow(); !ok || probe {
+		t.Fatal("closed breaker should allow without probing")
+	}
+}
+
+func TestBreakerRejects(t *testing.T) {
+	log.New(nil)
+	srv, hits, _ := flaky(100)
+	defer srv.Close()
+	c := New("breaker", &UpstreamConfig{BaseUrl: srv.URL, Breaker: BreakerConfig{Threshold: 2, Cooldown: time.Minute}})
+	for i := 0; i < 3; i++ {
+		req, _ := c.NewRequest(context.Background(), http.MethodPost, "/", nil)
+		res, err := c.Do(req)
+		if i < 2 {
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+		} else if err != ErrCircuitOpen {
+			t.Fatalf("after threshold: %v", err)
+		}
+	}
+	if *hits != 2 || c.Stats().Rejected != 1 {
+		t.Errorf("hits %d, rejected %d", *hits, c.Stats().Rejected)
+	}
+}
+
+func TestStreamingBody(t *testing.T) {
+	log.New(nil)
+	payload := bytes.Repeat([]byte("0123456789"), 100*logBodyLimit)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer srv.Close()
+	for _, logBody := range []bool{false, true} {
+		c := New("stream", &UpstreamConfig{BaseUrl: srv.URL, LogBody: logBody})
+		req, _ := c.NewRequest(context.Background(), http.MethodGet, "/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := res.Body.(*cancelBody); !ok {
+			t.Fatalf("response body %T is not streamed", res.Body)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil || !bytes.Equal(body, payload) {
+			t.Errorf("logBody %v: read %d bytes: %v", logBody, len(body), err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats 上游调用指标
+type Stats struct {
+	Requests    int64         `json:"requests"`
+	Errors      int64         `json:"errors"`
+	ServerError int64         `json:"server_error"`
+	Retries     int64         `json:"retries"`
+	Rejected    int64         `json:"rejected"`
+	BreakerOpen bool          `json:"breaker_open"`
+	AvgLatency  time.Duration `json:"avg_latency"`
+	MaxLatency  time.Duration `json:"max_latency"`
+	StatusCodes map[int]int64 `json:"status_codes"`
+}
+
+type stats struct {
+	sync.Mutex
+	requests    int64
+	errors      int64
+	serverError int64
+	retries     int64
+	rejected    int64
+	latency     time.Duration
+	maxLatency  time.Duration
+	statusCodes map[int]int64
+}
+
+func (s *stats) observe(status int, d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.requests++
+	s.latency += d
+	if d > s.maxLatency {
+		s.maxLatency = d
+	}
+	if status == 0 {
+		s.errors++
+		return
+	}
+	if status >= 500 {
+		s.serverError++
+	}
+	if s.statusCodes == nil {
+		s.statusCodes = make(map[int]int64)
+	}
+	s.statusCodes[status]++
+}
+
+func (s *stats) retry() {
+	s.Lock()
+	s.retries++
+	s.Unlock()
+}
+
+func (s *stats) reject() {
+	s.Lock()
+	s.rejected++
+	s.Unlock()
+}
+
+// Stats 返回客户端调用指标
+func (c *Client) Stats() Stats {
+	c.stats.Lock()
+	defer c.stats.Unlock()
+	st := Stats{
+		Requests:    c.stats.requests,
+		Errors:      c.stats.errors,
+		ServerError: c.stats.serverError,
+		Retries:     c.stats.retries,
+		Rejected:    c.stats.rejected,
+		BreakerOpen: c.breaker.open(),
+		MaxLatency:  c.stats.maxLatency,
+		StatusCodes: make(map[int]int64, len(c.stats.statusCodes)),
+	}
+	if c.stats.requests > 0 {
+		st.AvgLatency = c.stats.latency / time.Duration(c.stats.requests)
+	}
+	for k, v := range c.stats.statusCodes {
+		st.StatusCodes[k] = v
+	}
+	return st
+}
+
+// Metrics 返回全部已注册上游的调用指标
+func Metrics() map[string]Stats {
+	m := make(map[string]Stats, len(clients))
+	for name, c := range clients {
+		m[name] = c.Stats()
+	}
+	return m
+}
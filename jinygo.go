@@ -11,7 +11,11 @@ import (
 	"github.com/jinycoo/jinygo/log"
 	"github.com/jinycoo/jinygo/cache"
 	"github.com/jinycoo/jinygo/mqueue"
+	"github.com/jinycoo/jinygo/storage"
 	"github.com/jinycoo/jinygo/constants"
+	"github.com/jinycoo/jinygo/httpclient"
+	"github.com/jinycoo/jinygo/migrations"
+	"github.com/jinycoo/jinygo/rbac"
 )
 
 type Jinygo struct {
@@ -50,36 +54,84 @@ func (jiny *Jinygo) RGroup(name string) *web.RuGroup {
 }
 
 func (jiny *Jinygo) Run(params ...string) {
-	jiny.initApp()
+	err := jiny.initApp()
 	log.New(jiny.config.Logger)
 	defer log.Sync()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 	if len(jiny.config.Components) > 0 {
 		if dbFile, ok := jiny.config.Components[constants.ConfigFileDB]; ok && dbFile != "" {
 			if file := jiny.getModConfigFile(dbFile); file != "" {
-				db.Init(file)
+				if err := db.Init(file); err != nil {
+					log.Fatal(err.Error())
+				}
 				defer db.Close()
+				if m := jiny.config.Migrate; m != nil && m.Auto {
+					dir := m.Dir
+					if !path.IsAbs(dir) {
+						dir = path.Join(jiny.basePath, dir)
+					}
+					if err := migrations.UpAll(dir); err != nil {
+						log.Fatal("数据库迁移失败：" + err.Error())
+					}
+				}
 			} else {
 				log.Error(dbFile + ".yml 配置文件未找到，请检查配置是否正确")
 			}
 		}
 		if cacheFile, ok := jiny.config.Components[constants.ConfigFileCache]; ok && cacheFile != "" {
 			if file := jiny.getModConfigFile(cacheFile); file != "" {
-				cache.Init(file)
+				if err := cache.Init(file); err != nil {
+					log.Fatal(err.Error())
+				}
 			} else {
 				log.Error(cacheFile + ".yml 配置文件未找到，请检查配置是否正确")
 			}
 		}
 		if mqFile, ok := jiny.config.Components[constants.ConfigFileMQ]; ok && mqFile != "" {
 			if file := jiny.getModConfigFile(mqFile); file != "" {
-				mqueue.Init(file)
+				if err := mqueue.Init(file); err != nil {
+					log.Fatal(err.Error())
+				}
 				defer mqueue.Mqueue.Close()
 			} else {
 				log.Error(mqFile + ".yml 配置文件未找到，请检查配置是否正确")
 			}
 		}
+		if storageFile, ok := jiny.config.Components[constants.ConfigFileStorage]; ok && storageFile != "" {
+			if file := jiny.getModConfigFile(storageFile); file != "" {
+				if err := storage.Init(file); err != nil {
+					log.Fatal(err.Error())
+				}
+			} else {
+				log.Error(storageFile + ".yml 配置文件未找到，请检查配置是否正确")
+			}
+		}
+		if httpFile, ok := jiny.config.Components[constants.ConfigFileHttpClient]; ok && httpFile != "" {
+			if file := jiny.getModConfigFile(httpFile); file != "" {
+				if err := httpclient.Init(file); err != nil {
+					log.Fatal(err.Error())
+				}
+			} else {
+				log.Error(httpFile + ".yml 配置文件未找到，请检查配置是否正确")
+			}
+		}
+		if rbacFile, ok := jiny.config.Components[constants.ConfigFileRbac]; ok && rbacFile != "" {
+			if file := jiny.getModConfigFile(rbacFile); file != "" {
+				if err := rbac.Init(file); err != nil {
+					log.Fatal(err.Error())
+				}
+			} else {
+				// 未加载策略时 Authorize 拒绝全部请求，启动失败以便尽早发现
+				log.Fatal(rbacFile + ".yml 配置文件未找到，请检查配置是否正确")
+			}
+		}
 		if paramsFile, ok := jiny.config.Components[constants.ConfigFileParams]; ok && paramsFile != "" {
 			if file := jiny.getModConfigFile(paramsFile); file != "" {
-				initParams(file)
+				if err := initParams(file); err != nil {
+					log.Fatal(err.Error())
+				}
 			} else {
 				log.Error(paramsFile + ".yml 配置文件未找到，请检查配置是否正确")
 			}
@@ -94,5 +146,12 @@ func (jiny *Jinygo) Run(params ...string) {
 			jiny.config.WebPort, _ = strconv.Atoi(addr[1])
 		}
 	}
+	web.DefaultTimeout = jiny.config.Timeout
+	if jiny.config.Format != "" {
+		web.DefaultFormat = jiny.config.Format
+	}
+	if len(jiny.config.Static) > 0 {
+		web.Statics = jiny.config.Static
+	}
 	web.Run(jiny.config.RunMode, fmt.Sprintf("%s:%d", cfg.WebHost, cfg.WebPort))
 }
\ No newline at end of file
@@ -0,0 +1,112 @@
+// Package jinygotest 提供进程内 HTTP 测试工具
+// 使用 web.Routes 构建 gin.Engine 而不监听端口，并以 SQLite、内存 Redis、内存消息队列替代 db、cache、mqueue 组件
+package jinygotest
+
+import (
+	"fmt"
+	"testing"
+	"github.com/gin-gonic/gin"
+	"github.com/go-xorm/xorm"
+	"github.com/go-redis/redis"
+	"github.com/alicebob/miniredis"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/jinycoo/jinygo/db"
+	"github.com/jinycoo/jinygo/web"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/cache"
+	"github.com/jinycoo/jinygo/mqueue"
+)
+
+type (
+	Option func(app *App) error
+
+	App struct {
+		Engine *gin.Engine
+		Redis  *miniredis.Miniredis
+		MQ     *mqueue.Memory
+
+		t       testing.TB
+		dbNames []string
+	}
+)
+
+// WithSQLite 为每个数据库名注册内存 SQLite 数据组，db.Use(name) 即返回该数据组
+func WithSQLite(dbNames ...string) Option {
+	return func(app *App) error {
+		for _, name := range dbNames {
+			dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", name)
+			group, err := xorm.NewEngineGroup("sqlite3", []string{dsn})
+			if err != nil {
+				return err
+			}
+			// 内存数据库在最后一个连接关闭时销毁
+			group.SetMaxIdleConns(1)
+			db.Register(name, group)
+			app.dbNames = append(app.dbNames, name)
+		}
+		return nil
+	}
+}
+
+// WithRedis 启动内存 Redis 服务并替换 cache.RCache
+func WithRedis() Option {
+	return func(app *App) error {
+		mr, err := miniredis.Run()
+		if err != nil {
+			return err
+		}
+		app.Redis = mr
+		cache.RCache = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		return nil
+	}
+}
+
+// WithMQ 使用内存消息队列替换 mqueue.Mqueue，config 与 mqueue.yml 中的单个队列配置一致
+func WithMQ(config *mqueue.Config) Option {
+	return func(app *App) error {
+		app.MQ = mqueue.NewMemory(config)
+		mqueue.Mqueue = app.MQ
+		return nil
+	}
+}
+
+// New 构建测试应用，测试结束时自动释放组件
+func New(t testing.TB, opts ...Option) *App {
+	t.Helper()
+	cfg := log.DevConfig()
+	cfg.OutPuts = []string{log.Stderr}
+	log.New(cfg)
+
+	app := &App{t: t}
+	for _, opt := range opts {
+		if err := opt(app); err != nil {
+			app.Close()
+			t.Fatalf("jinygotest: %v", err)
+		}
+	}
+	app.Engine = web.Engine(gin.TestMode)
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(app.Close)
+	}
+	return app
+}
+
+func (app *App) Close() {
+	for _, name := range app.dbNames {
+		if g, ok := db.DataGroup[name]; ok {
+			g.Close()
+			delete(db.DataGroup, name)
+		}
+	}
+	app.dbNames = nil
+	if app.Redis != nil {
+		cache.RCache.Close()
+		cache.RCache = nil
+		app.Redis.Close()
+		app.Redis = nil
+	}
+	if app.MQ != nil {
+		mqueue.Mqueue = nil
+		app.MQ = nil
+	}
+}
@@ -0,0 +1,162 @@
+package jinygotest
+
+import (
+	"io"
+	"bytes"
+	"strings"
+	"reflect"
+	"strconv"
+	"testing"
+	"net/http"
+	"net/http/httptest"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/web"
+	"github.com/jinycoo/jinygo/constants"
+)
+
+type Response struct {
+	*httptest.ResponseRecorder
+	t   testing.TB
+	api *web.ApiResponse
+}
+
+func (app *App) Get(path string, header ...http.Header) *Response {
+	return app.Request(constants.MethodGet, path, nil, header...)
+}
+
+func (app *App) Post(path string, body interface{}, header ...http.Header) *Response {
+	return app.Request(constants.MethodPost, path, body, header...)
+}
+
+func (app *App) Put(path string, body interface{}, header ...http.Header) *Response {
+	return app.Request(constants.MethodPut, path, body, header...)
+}
+
+func (app *App) Del(path string, header ...http.Header) *Response {
+	return app.Request(constants.MethodDelete, path, nil, header...)
+}
+
+// Request 发起请求，body 为 string、[]byte、io.Reader 时原样发送，否则编码为 JSON
+func (app *App) Request(method, path string, body interface{}, header ...http.Header) *Response {
+	app.t.Helper()
+	var reader io.Reader
+	isJson := false
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	case io.Reader:
+		reader = b
+	default:
+		buf, err := jsoniter.Marshal(b)
+		if err != nil {
+			app.t.Fatalf("jinygotest: marshal body: %v", err)
+		}
+		reader = bytes.NewReader(buf)
+		isJson = true
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if isJson {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, h := range header {
+		for k, v := range h {
+			req.Header[k] = v
+		}
+	}
+	return app.Do(req)
+}
+
+func (app *App) Do(req *http.Request) *Response {
+	w := httptest.NewRecorder()
+	app.Engine.ServeHTTP(w, req)
+	return &Response{ResponseRecorder: w, t: app.t}
+}
+
+// Api 将响应体解析为 web.ApiResponse
+func (r *Response) Api() *web.ApiResponse {
+	r.t.Helper()
+	if r.api == nil {
+		r.api = new(web.ApiResponse)
+		if err := jsoniter.Unmarshal(r.Body.Bytes(), r.api); err != nil {
+			r.t.Errorf("jinygotest: response is not ApiResponse: %v, body: %s", err, r.Body.String())
+		}
+	}
+	return r.api
+}
+
+// Decode 将 attachment 解析到 v
+func (r *Response) Decode(v interface{}) {
+	r.t.Helper()
+	buf, _ := jsoniter.Marshal(r.Api().Data)
+	if err := jsoniter.Unmarshal(buf, v); err != nil {
+		r.t.Errorf("jinygotest: decode attachment: %v", err)
+	}
+}
+
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Errorf("jinygotest: expected status %d, got %d, body: %s", code, r.Code, r.Body.String())
+	}
+	return r
+}
+
+func (r *Response) ExpectErrCode(code int) *Response {
+	r.t.Helper()
+	if got := r.Api().ErrCode; got != code {
+		r.t.Errorf("jinygotest: expected msg_code %d, got %d (%s)", code, got, r.Api().Message)
+	}
+	return r
+}
+
+// ExpectData 断言 attachment 中以点号分隔路径取得的值，例如 "user.name"、"list.0.id"
+func (r *Response) ExpectData(path string, want interface{}) *Response {
+	r.t.Helper()
+	got, ok := lookup(r.Api().Data, path)
+	if !ok {
+		r.t.Errorf("jinygotest: attachment has no %q, body: %s", path, r.Body.String())
+		return r
+	}
+	if !equalJson(got, want) {
+		r.t.Errorf("jinygotest: attachment %q expected %v, got %v", path, want, got)
+	}
+	return r
+}
+
+func lookup(data interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return data, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			val, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			data = val
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			data = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+func equalJson(got, want interface{}) bool {
+	buf, err := jsoniter.Marshal(want)
+	if err != nil {
+		return false
+	}
+	var normalized interface{}
+	jsoniter.Unmarshal(buf, &normalized)
+	return reflect.DeepEqual(got, normalized)
+}
@@ -0,0 +1,21 @@
+package log
+
+import "context"
+
+type requestIdKey struct{}
+
+// WithRequestId 将请求 ID 写入上下文，db、httpclient 等组件记录日志或调用上游时从中读取
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId 返回上下文中的请求 ID
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(requestIdKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
@@ -10,6 +10,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"github.com/jinycoo/jinygo/utils"
+	"github.com/jinycoo/jinygo/secret"
 )
 
 const (
@@ -212,13 +213,13 @@ func (jlc *JLogConfig) callerEncoder() {
 func (jl *JLogger) check(lvl zapcore.Level, msg string) *zapcore.CheckedEntry {
 	const callerSkipOffset = 2
 	if JLog == nil {
-		initJLog(nil)
+		New(nil)
 	}
 	ent := zapcore.Entry{
 		LoggerName: JLog.name,
 		Time:       time.Now().Local(),
 		Level:      lvl,
-		Message:    msg,
+		Message:    secret.Mask(msg),
 	}
 	ce := JLog.core.Check(ent, nil)
 	willWrite := ce != nil
@@ -289,6 +290,24 @@ func Error(details ...interface{}) {
 		ce.Write()
 	}
 }
+func CWarn(msg string, fields map[string]interface{}) {
+	if len(fields) > 0 {
+		if ce := JLog.check(zapcore.WarnLevel, msg); ce != nil {
+			ce.Write(genFields(fields)...)
+		}
+	} else {
+		Warn(msg)
+	}
+}
+func CError(msg string, fields map[string]interface{}) {
+	if len(fields) > 0 {
+		if ce := JLog.check(zapcore.ErrorLevel, msg); ce != nil {
+			ce.Write(genFields(fields)...)
+		}
+	} else {
+		Error(msg)
+	}
+}
 func DPanic(details ...interface{}) {
 	if ce := JLog.check(zapcore.DPanicLevel, fmt.Sprint(details...)); ce != nil {
 		ce.Write()
@@ -323,7 +342,9 @@ func genFields(details map[string]interface{}) []zapcore.Field {
 		case int64:
 			fields = append(fields, zap.Int64(k, v.(int64)))
 		case string:
-			fields = append(fields, zap.String(k, v.(string)))
+			fields = append(fields, zap.String(k, secret.Mask(v.(string))))
+		case error:
+			fields = append(fields, zap.String(k, secret.Mask(v.(error).Error())))
 		default:
 			fields = append(fields, zap.Reflect(k, v))
 		}
@@ -0,0 +1,435 @@
+// Package migrations 按数据组管理数据库结构变更
+// SQL 迁移文件位于 <dir>/<db_name>/ 下，命名为 <version>_<name>.up.sql 及 <version>_<name>.down.sql，
+// Go 迁移通过 Register 注册，已执行的版本及校验和记录在 schema_migrations 表中
+package migrations
+
+import (
+	"os"
+	"fmt"
+	"sort"
+	"time"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"io/ioutil"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"github.com/go-xorm/xorm"
+	"github.com/jinycoo/jinygo/db"
+	"github.com/jinycoo/jinygo/log"
+)
+
+const (
+	DefaultTable       = "schema_migrations"
+	DefaultLockTimeout = time.Minute
+	// lockExpire 超过该时长未刷新的锁视为持有实例已退出，持有期间每 lockRefresh 刷新一次
+	lockExpire  = 10 * time.Minute
+	lockRefresh = lockExpire / 5
+)
+
+var (
+	ErrLocked = errors.New("migrations: lock is held by another instance")
+	ErrNoDown = errors.New("migrations: down migration not defined")
+
+	fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+	registry = make(map[string][]*Migration)
+)
+
+type (
+	// Func Go 迁移函数，在事务会话中执行
+	Func func(s *xorm.Session) error
+
+	// Config app.yml 中的 migrate 配置，Auto 为 true 时在 Jinygo.Run 启动时执行未执行的迁移
+	Config struct {
+		Dir  string `yaml:"dir"`
+		Auto bool   `yaml:"auto"`
+	}
+
+	Migration struct {
+		Version  int64
+		Name     string
+		Up       string
+		Down     string
+		UpFunc   Func
+		DownFunc Func
+		checksum string
+	}
+
+	// Status 迁移状态，Modified 表示已执行的迁移文件内容发生变化
+	Status struct {
+		Version   int64
+		Name      string
+		Applied   bool
+		AppliedAt time.Time
+		Modified  bool
+		Missing   bool
+	}
+
+	// Migrator 单个数据组的迁移执行器，DryRun 为 true 时仅输出待执行的 SQL
+	Migrator struct {
+		DbName      string
+		Table       string
+		DryRun      bool
+		LockTimeout time.Duration
+		migrations  []*Migration
+	}
+
+	applied struct {
+		version   int64
+		name      string
+		checksum  string
+		appliedAt time.Time
+	}
+)
+
+// Register 注册 Go 迁移
+func Register(dbName string, version int64, name string, up, down Func) {
+	registry[dbName] = append(registry[dbName], &Migration{Version: version, Name: name, UpFunc: up, DownFunc: down})
+}
+
+// New 读取 dir/dbName 下的迁移文件并合并已注册的 Go 迁移
+func New(dbName, dir string) (*Migrator, error) {
+	m := &Migrator{DbName: dbName, Table: DefaultTable, LockTimeout: DefaultLockTimeout}
+	byVersion := make(map[int64]*Migration)
+	for _, mg := range registry[dbName] {
+		if _, ok := byVersion[mg.Version]; ok {
+			return nil, fmt.Errorf("migrations: duplicate version %d", mg.Version)
+		}
+		byVersion[mg.Version] = mg
+	}
+	files, err := ioutil.ReadDir(filepath.Join(dir, dbName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, f := range files {
+		match := fileName.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		buf, err := ioutil.ReadFile(filepath.Join(dir, dbName, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mg
+		} else if mg.Name != match[2] || mg.UpFunc != nil {
+			return nil, fmt.Errorf("migrations: duplicate version %d", version)
+		}
+		if match[3] == "up" {
+			mg.Up = string(buf)
+		} else {
+			mg.Down = string(buf)
+		}
+	}
+	for _, mg := range byVersion {
+		if mg.Up == "" && mg.UpFunc == nil {
+			return nil, fmt.Errorf("migrations: version %d has no up migration", mg.Version)
+		}
+		sum := sha256.Sum256([]byte(mg.Name + "\n" + mg.Up))
+		mg.checksum = hex.EncodeToString(sum[:])
+		m.migrations = append(m.migrations, mg)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	return m, nil
+}
+
+// Up 执行全部未执行的迁移
+func (m *Migrator) Up() error {
+	return m.To(-1)
+}
+
+// Down 回滚最近执行的 steps 个迁移
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func(engine *xorm.Engine, done map[int64]*applied) error {
+		versions := make([]int64, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		for i := 0; i < steps && i < len(versions); i++ {
+			if err := m.rollback(engine, m.find(versions[i], done[versions[i]])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// To 迁移至指定版本：执行不大于 version 的未执行迁移，回滚大于 version 的已执行迁移，version 为 -1 时执行全部
+func (m *Migrator) To(version int64) error {
+	return m.withLock(func(engine *xorm.Engine, done map[int64]*applied) error {
+		for _, mg := range m.migrations {
+			if _, ok := done[mg.Version]; ok || version >= 0 && mg.Version > version {
+				continue
+			}
+			if err := m.apply(engine, mg); err != nil {
+				return err
+			}
+		}
+		if version < 0 {
+			return nil
+		}
+		versions := make([]int64, 0)
+		for v := range done {
+			if v > version {
+				versions = append(versions, v)
+			}
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+		for _, v := range versions {
+			if err := m.rollback(engine, m.find(v, done[v])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status 返回全部迁移的执行状态，包括已执行但迁移文件已删除的版本
+func (m *Migrator) Status() ([]*Status, error) {
+	engine := db.Master(m.DbName)
+	if engine == nil {
+		return nil, errors.New(m.DbName + " - Database does not exist.")
+	}
+	if err := m.createTable(engine); err != nil {
+		return nil, err
+	}
+	done, err := m.applied(engine)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		st := &Status{Version: mg.Version, Name: mg.Name}
+		if a, ok := done[mg.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = a.appliedAt
+			st.Modified = a.checksum != mg.checksum
+			delete(done, mg.Version)
+		}
+		list = append(list, st)
+	}
+	for _, a := range done {
+		list = append(list, &Status{Version: a.version, Name: a.name, Applied: true, AppliedAt: a.appliedAt, Missing: true})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func (m *Migrator) find(version int64, a *applied) *Migration {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg
+		}
+	}
+	return &Migration{Version: version, Name: a.name}
+}
+
+func (m *Migrator) apply(engine *xorm.Engine, mg *Migration) error {
+	log.Info(fmt.Sprintf("%s migrate up %d_%s", m.DbName, mg.Version, mg.Name))
+	return m.run(engine, mg.Up, mg.UpFunc, func(s *xorm.Session) error {
+		_, err := s.Exec("INSERT INTO " + m.Table + " (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+			mg.Version, mg.Name, mg.checksum, time.Now().Unix())
+		return err
+	})
+}
+
+func (m *Migrator) rollback(engine *xorm.Engine, mg *Migration) error {
+	if mg.Down == "" && mg.DownFunc == nil {
+		return fmt.Errorf("%v: %d_%s", ErrNoDown, mg.Version, mg.Name)
+	}
+	log.Info(fmt.Sprintf("%s migrate down %d_%s", m.DbName, mg.Version, mg.Name))
+	return m.run(engine, mg.Down, mg.DownFunc, func(s *xorm.Session) error {
+		_, err := s.Exec("DELETE FROM " + m.Table + " WHERE version = ?", mg.Version)
+		return err
+	})
+}
+
+// run 在事务中执行迁移 SQL 或函数并更新迁移表，MySQL 的 DDL 语句会隐式提交
+func (m *Migrator) run(engine *xorm.Engine, sql string, fn Func, record Func) error {
+	if m.DryRun {
+		for _, stmt := range splitStatements(sql) {
+			log.Info(stmt + ";")
+		}
+		if fn != nil {
+			log.Info("-- go migration function")
+		}
+		return nil
+	}
+	s := engine.NewSession()
+	defer s.Close()
+	if err := s.Begin(); err != nil {
+		return err
+	}
+	for _, stmt := range splitStatements(sql) {
+		if _, err := s.Exec(stmt); err != nil {
+			s.Rollback()
+			return fmt.Errorf("%v\n%s", err, stmt)
+		}
+	}
+	if fn != nil {
+		if err := fn(s); err != nil {
+			s.Rollback()
+			return err
+		}
+	}
+	if err := record(s); err != nil {
+		s.Rollback()
+		return err
+	}
+	return s.Commit()
+}
+
+func (m *Migrator) createTable(engine *xorm.Engine) error {
+	_, err := engine.Exec("CREATE TABLE IF NOT EXISTS " + m.Table + " (" +
+		"version BIGINT NOT NULL PRIMARY KEY, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"checksum VARCHAR(64) NOT NULL, " +
+		"applied_at BIGINT NOT NULL)")
+	if err != nil {
+		return err
+	}
+	_, err = engine.Exec("CREATE TABLE IF NOT EXISTS " + m.Table + "_lock (" +
+		"id INTEGER NOT NULL PRIMARY KEY, " +
+		"owner VARCHAR(255) NOT NULL, " +
+		"locked_at BIGINT NOT NULL)")
+	return err
+}
+
+func (m *Migrator) applied(engine *xorm.Engine) (map[int64]*applied, error) {
+	rows, err := engine.QueryString("SELECT version, name, checksum, applied_at FROM " + m.Table)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int64]*applied, len(rows))
+	for _, row := range rows {
+		version, _ := strconv.ParseInt(row["version"], 10, 64)
+		at, _ := strconv.ParseInt(row["applied_at"], 10, 64)
+		done[version] = &applied{version: version, name: row["name"], checksum: row["checksum"], appliedAt: time.Unix(at, 0)}
+	}
+	return done, nil
+}
+
+// withLock 获取迁移锁后执行 fn，锁通过向锁表插入唯一记录实现，适用于全部适配器
+func (m *Migrator) withLock(fn func(engine *xorm.Engine, done map[int64]*applied) error) error {
+	engine := db.Master(m.DbName)
+	if engine == nil {
+		return errors.New(m.DbName + " - Database does not exist.")
+	}
+	if err := m.createTable(engine); err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	token := make([]byte, 4)
+	rand.Read(token)
+	owner := fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(token))
+	lockTable := m.Table + "_lock"
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		engine.Exec("DELETE FROM " + lockTable + " WHERE locked_at < ?", time.Now().Add(-lockExpire).Unix())
+		_, err := engine.Exec("INSERT INTO " + lockTable + " (id, owner, locked_at) VALUES (1, ?, ?)", owner, time.Now().Unix())
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		time.Sleep(time.Second)
+	}
+	defer engine.Exec("DELETE FROM " + lockTable + " WHERE id = 1 AND owner = ?", owner)
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.refreshLock(engine, lockTable, owner, stop)
+
+	done, err := m.applied(engine)
+	if err != nil {
+		return err
+	}
+	for _, mg := range m.migrations {
+		if a, ok := done[mg.Version]; ok && a.checksum != mg.checksum {
+			log.Warn(fmt.Sprintf("%s migration %d_%s 已执行但内容已修改", m.DbName, mg.Version, mg.Name))
+		}
+	}
+	return fn(engine, done)
+}
+
+// refreshLock 定时刷新锁的时间，避免耗时较长的迁移被其他实例视为过期锁
+func (m *Migrator) refreshLock(engine *xorm.Engine, lockTable, owner string, stop chan struct{}) {
+	ticker := time.NewTicker(lockRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			res, err := engine.Exec("UPDATE " + lockTable + " SET locked_at = ? WHERE id = 1 AND owner = ?", time.Now().Unix(), owner)
+			if err != nil {
+				log.Warn(m.DbName + " 迁移锁刷新失败：" + err.Error())
+				continue
+			}
+			if n, err := res.RowsAffected(); err == nil && n == 0 {
+				log.Error(m.DbName + " 迁移锁已丢失")
+				return
+			}
+		}
+	}
+}
+
+// splitStatements 按语句末尾的分号拆分 SQL，忽略引号内的分号及注释行
+func splitStatements(sql string) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+		quote rune
+	)
+	for _, line := range strings.Split(sql, "\n") {
+		if quote == 0 && strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		for _, r := range line {
+			switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '\'' || r == '"' || r == '`':
+				quote = r
+			case r == ';':
+				if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+					stmts = append(stmts, stmt)
+				}
+				buf.Reset()
+				continue
+			}
+			buf.WriteRune(r)
+		}
+		buf.WriteByte('\n')
+	}
+	if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// UpAll 为全部已连接的数据组执行未执行的迁移
+func UpAll(dir string) error {
+	for name := range db.DataGroup {
+		m, err := New(name, dir)
+		if err != nil {
+			return err
+		}
+		if len(m.migrations) == 0 {
+			continue
+		}
+		if err := m.Up(); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package mqueue
+
+import (
+	"fmt"
+	"context"
+	"sync"
+	"strings"
+)
+
+// Memory 进程内消息队列，按配置中的 exchange/queue 绑定关系路由消息，用于测试替代 RabbitMQ
+// 消息在 Produce 时同步投递给已设置处理函数的消费者，未设置时暂存至队列
+type Memory struct {
+	sync.Mutex
+	config       *Config
+	errorChannel chan error
+	queues       map[string]*memoryQueue
+	producers    map[string]*memoryProducer
+	consumers    map[string]*memoryConsumer
+}
+
+type memoryQueue struct {
+	name     string
+	pending  [][]byte
+	history  [][]byte
+	handlers []ConsumerHandler
+}
+
+type memoryProducer struct {
+	mq     *Memory
+	config ProducerConfig
+}
+
+type memoryConsumer struct {
+	mq    *Memory
+	queue string
+	once  sync.Once
+}
+
+func NewMemory(config *Config) *Memory {
+	if config == nil {
+		config = &Config{}
+	}
+	m := &Memory{
+		config:       config,
+		errorChannel: make(chan error),
+		queues:       make(map[string]*memoryQueue),
+		producers:    make(map[string]*memoryProducer),
+		consumers:    make(map[string]*memoryConsumer),
+	}
+	for _, q := range config.Queues {
+		m.queues[q.Name] = &memoryQueue{name: q.Name}
+	}
+	for _, p := range config.Producers {
+		m.producers[p.Name] = &memoryProducer{mq: m, config: p}
+	}
+	for _, c := range config.Consumers {
+		m.consumers[c.Name] = &memoryConsumer{mq: m, queue: c.Queue}
+	}
+	return m
+}
+
+func (m *Memory) GetConsumer(name string) (Consumer, error) {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.consumers[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("consumer '%s' is not registered. Check your configuration", name)
+}
+
+func (m *Memory) SetConsumerHandler(name string, handler ConsumerHandler) error {
+	consumer, err := m.GetConsumer(name)
+	if err != nil {
+		return err
+	}
+	consumer.Consume(handler)
+	return nil
+}
+
+func (m *Memory) GetProducer(name string) (Producer, error) {
+	m.Lock()
+	defer m.Unlock()
+	if p, ok := m.producers[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("producer '%s' is not registered. Check your configuration", name)
+}
+
+func (m *Memory) Error() <-chan error {
+	return m.errorChannel
+}
+
+func (m *Memory) Close() {}
+
+// Messages 返回投递到队列的全部消息
+func (m *Memory) Messages(queue string) [][]byte {
+	m.Lock()
+	defer m.Unlock()
+	if q, ok := m.queues[queue]; ok {
+		return append([][]byte{}, q.history...)
+	}
+	return nil
+}
+
+func (m *Memory) route(exchange, routingKey string) []*memoryQueue {
+	if exchange == "" {
+		if q, ok := m.queues[routingKey]; ok {
+			return []*memoryQueue{q}
+		}
+		return nil
+	}
+	var kind string
+	for _, e := range m.config.Exchanges {
+		if e.Name == exchange {
+			kind = e.Type
+		}
+	}
+	var queues []*memoryQueue
+	for _, qc := range m.config.Queues {
+		if qc.Exchange != exchange {
+			continue
+		}
+		var matched bool
+		switch kind {
+		case "fanout":
+			matched = true
+		case "topic":
+			matched = topicMatch(strings.Split(qc.RoutingKey, "."), strings.Split(routingKey, "."))
+		default:
+			matched = qc.RoutingKey == routingKey
+		}
+		if matched {
+			queues = append(queues, m.queues[qc.Name])
+		}
+	}
+	return queues
+}
+
+func (p *memoryProducer) Produce(data []byte) {
+	var deliveries []func()
+	p.mq.Lock()
+	for _, q := range p.mq.route(p.config.Exchange, p.config.RoutingKey) {
+		q.history = append(q.history, data)
+		if len(q.handlers) == 0 {
+			q.pending = append(q.pending, data)
+			continue
+		}
+		handler := q.handlers[(len(q.history)-1)%len(q.handlers)]
+		deliveries = append(deliveries, func() { handler(data) })
+	}
+	p.mq.Unlock()
+	for _, deliver := range deliveries {
+		deliver()
+	}
+}
+
+func (p *memoryProducer) ProduceContext(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.Produce(data)
+	return nil
+}
+
+func (c *memoryConsumer) Consume(handler ConsumerHandler) {
+	c.once.Do(func() {
+		c.mq.Lock()
+		q, ok := c.mq.queues[c.queue]
+		if !ok {
+			q = &memoryQueue{name: c.queue}
+			c.mq.queues[c.queue] = q
+		}
+		q.handlers = append(q.handlers, handler)
+		pending := q.pending
+		q.pending = nil
+		c.mq.Unlock()
+		for _, data := range pending {
+			handler(data)
+		}
+	})
+}
+
+func topicMatch(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatch(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatch(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && topicMatch(pattern[1:], words[1:])
+	}
+}
@@ -6,10 +6,10 @@ import (
 	"time"
 	"io/ioutil"
 	"sync/atomic"
-	"gopkg.in/yaml.v2"
 	"github.com/streadway/amqp"
 
 	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
 )
 
 const (
@@ -49,22 +49,24 @@ type mq struct {
 	reconnectStatus int32
 }
 
-func Init(file string) {
+func Init(file string) error {
 	buf, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Warn(file + "文件读取失败")
+		return fmt.Errorf("%s文件读取失败：%v", file, err)
 	}
-	err = yaml.Unmarshal(buf, &mqCfg)
+	err = secret.Unmarshal(buf, &mqCfg)
 	if err != nil {
-		log.Warn(file + "解析失败")
+		return fmt.Errorf("%s解析失败：%v", file, err)
 	}
 	if mqCfg.Queues != nil && len(mqCfg.Queues) > 0 {
 		if rc, ok := mqCfg.Queues["rabbit"]; ok {
+			secret.RegisterUrl(rc.DSN)
 			Mqueue, err = New(rc)
 		}
 	} else {
 		log.Warn("队列配置参数缺失")
 	}
+	return err
 }
 
 func New(config *Config) (MQ, error) {
@@ -2,11 +2,13 @@ package mqueue
 
 import (
 	"sync"
+	"context"
 	"github.com/streadway/amqp"
 )
 
 type Producer interface {
 	Produce(data []byte)
+	ProduceContext(ctx context.Context, data []byte) error
 }
 
 type producer struct {
@@ -76,6 +78,16 @@ func (producer *producer) Produce(message []byte) {
 	producer.publishChannel <- message
 }
 
+// ProduceContext 发送缓冲区已满时等待，ctx 取消或超时后放弃发送
+func (producer *producer) ProduceContext(ctx context.Context, message []byte) error {
+	select {
+	case producer.publishChannel <- message:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (producer *producer) produce(message []byte) error {
 	producer.Lock()
 	defer producer.Unlock()
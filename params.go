@@ -1,9 +1,9 @@
 package jinygo
 
 import (
+	"fmt"
 	"io/ioutil"
-	"gopkg.in/yaml.v2"
-	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
 	"strings"
 	"reflect"
 	"strconv"
@@ -97,13 +97,14 @@ func GetParamString(key string) string {
 	return result
 }
 
-func initParams(file string) {
+func initParams(file string) error {
 	buf, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Warn(file + "文件读取失败")
+		return fmt.Errorf("%s文件读取失败：%v", file, err)
 	}
-	err = yaml.Unmarshal(buf, &Params)
+	err = secret.Unmarshal(buf, &Params)
 	if err != nil {
-		log.Warn(file + "解析失败")
+		return fmt.Errorf("%s解析失败：%v", file, err)
 	}
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,277 @@
+package rbac
+
+import (
+	"os"
+	"fmt"
+	"sync"
+	"time"
+	"errors"
+	"strings"
+	"io/ioutil"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
+)
+
+const (
+	Wildcard = "*"
+	OwnSuffix = ":own"
+)
+
+var (
+	Default = New(nil)
+
+	rbacCfg *Config
+)
+
+type (
+	// Config rbac.yml 配置，Reload 为策略热加载检查间隔，为 0 时不自动重新加载
+	Config struct {
+		Reload time.Duration `yaml:"reload"`
+		Policy `yaml:",inline"`
+	}
+
+	// Policy 角色、权限及路由与权限的对应关系
+	Policy struct {
+		Roles  map[string]*Role `yaml:"roles"`
+		Routes []*Rule          `yaml:"routes"`
+	}
+
+	// Role 角色，Inherits 为继承的角色，权限支持 article:* 及 * 通配
+	Role struct {
+		Inherits    []string `yaml:"inherits"`
+		Permissions []string `yaml:"permissions"`
+	}
+
+	// Rule 路由权限规则，Path 为路由模板（例如 /v1/article/update/:id），以 /* 结尾时匹配前缀
+	// Method 为空或 * 时匹配全部方法；Owner 为资源归属校验回调名称，
+	// 主体无 Permission 但拥有 Permission + ":own" 且回调校验通过时允许访问
+	Rule struct {
+		Method     string `yaml:"method"`
+		Path       string `yaml:"path"`
+		Permission string `yaml:"permission"`
+		Owner      string `yaml:"owner"`
+	}
+
+	// Loader 策略来源，例如 yml 文件或数据库
+	Loader interface {
+		Load() (*Policy, error)
+	}
+
+	// Enforcer 未设置策略前 Loaded 为 false，Authorize 拒绝全部请求
+	Enforcer struct {
+		sync.RWMutex
+		loader      Loader
+		loaded      bool
+		policy      *Policy
+		permissions map[string]map[string]bool
+		stop        chan struct{}
+	}
+
+	fileLoader struct {
+		file    string
+		modTime time.Time
+	}
+)
+
+func Init(cfgFile string) error {
+	buf, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("%s文件读取失败：%v", cfgFile, err)
+	}
+	err = secret.Unmarshal(buf, &rbacCfg)
+	if err != nil {
+		return fmt.Errorf("%s解析失败：%v", cfgFile, err)
+	}
+	if rbacCfg == nil {
+		return fmt.Errorf("%s解析失败：配置为空", cfgFile)
+	}
+	Default.SetPolicy(&rbacCfg.Policy)
+	Default.loader = FileLoader(cfgFile)
+	if rbacCfg.Reload > 0 {
+		Default.Watch(rbacCfg.Reload)
+	}
+	return nil
+}
+
+// FileLoader 从 yml 文件加载策略，文件未修改时返回 nil
+func FileLoader(file string) Loader {
+	fl := &fileLoader{file: file}
+	if stat, err := os.Stat(file); err == nil {
+		fl.modTime = stat.ModTime()
+	}
+	return fl
+}
+
+func (fl *fileLoader) Load() (*Policy, error) {
+	stat, err := os.Stat(fl.file)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.ModTime().After(fl.modTime) {
+		return nil, nil
+	}
+	buf, err := ioutil.ReadFile(fl.file)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := secret.Unmarshal(buf, &cfg); err != nil {
+		return nil, err
+	}
+	fl.modTime = stat.ModTime()
+	return &cfg.Policy, nil
+}
+
+func New(loader Loader) *Enforcer {
+	return &Enforcer{loader: loader, policy: &Policy{}, permissions: make(map[string]map[string]bool)}
+}
+
+// SetLoader 设置策略来源并立即加载
+func (e *Enforcer) SetLoader(loader Loader) error {
+	e.Lock()
+	e.loader = loader
+	e.Unlock()
+	return e.Reload()
+}
+
+// Reload 从策略来源重新加载，加载失败时保留原有策略
+func (e *Enforcer) Reload() error {
+	e.RLock()
+	loader := e.loader
+	e.RUnlock()
+	if loader == nil {
+		return errors.New("rbac: policy loader not set")
+	}
+	policy, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	if policy != nil {
+		e.SetPolicy(policy)
+		log.Info("rbac 策略已重新加载")
+	}
+	return nil
+}
+
+// Watch 每隔 interval 重新加载策略
+func (e *Enforcer) Watch(interval time.Duration) {
+	e.Lock()
+	if e.stop != nil {
+		close(e.stop)
+	}
+	stop := make(chan struct{})
+	e.stop = stop
+	e.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := e.Reload(); err != nil {
+					log.Warn("rbac 策略加载失败：" + err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func (e *Enforcer) Stop() {
+	e.Lock()
+	defer e.Unlock()
+	if e.stop != nil {
+		close(e.stop)
+		e.stop = nil
+	}
+}
+
+// SetPolicy 替换当前策略并展开角色继承
+func (e *Enforcer) SetPolicy(policy *Policy) {
+	permissions := make(map[string]map[string]bool, len(policy.Roles))
+	for name := range policy.Roles {
+		perms := make(map[string]bool)
+		expand(policy, name, perms, make(map[string]bool))
+		permissions[name] = perms
+	}
+	for _, rule := range policy.Routes {
+		rule.Method = strings.ToUpper(rule.Method)
+		rule.Path = "/" + strings.Trim(rule.Path, "/")
+	}
+	e.Lock()
+	e.policy = policy
+	e.permissions = permissions
+	e.loaded = true
+	e.Unlock()
+}
+
+// Loaded 返回是否已设置策略
+func (e *Enforcer) Loaded() bool {
+	e.RLock()
+	defer e.RUnlock()
+	return e.loaded
+}
+
+func expand(policy *Policy, name string, perms, visited map[string]bool) {
+	role, ok := policy.Roles[name]
+	if !ok || visited[name] {
+		return
+	}
+	visited[name] = true
+	for _, p := range role.Permissions {
+		perms[p] = true
+	}
+	for _, parent := range role.Inherits {
+		expand(policy, parent, perms, visited)
+	}
+}
+
+// Can 判断角色集合是否拥有权限
+func (e *Enforcer) Can(roles []string, permission string) bool {
+	e.RLock()
+	defer e.RUnlock()
+	for _, role := range roles {
+		perms := e.permissions[role]
+		if perms[Wildcard] || perms[permission] {
+			return true
+		}
+		for i := strings.LastIndex(permission, ":"); i > 0; i = strings.LastIndex(permission[:i], ":") {
+			if perms[permission[:i] + ":" + Wildcard] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Match 返回与请求方法及路由模板匹配的规则，精确匹配优先于前缀匹配，前缀较长的优先，路径相同时指定方法的规则优先
+func (e *Enforcer) Match(method, route string) *Rule {
+	e.RLock()
+	defer e.RUnlock()
+	var (
+		matched *Rule
+		best    int
+	)
+	for _, rule := range e.policy.Routes {
+		anyMethod := rule.Method == "" || rule.Method == Wildcard
+		if !anyMethod && rule.Method != method {
+			continue
+		}
+		var rank int
+		if rule.Path == route {
+			rank = 1 << 30
+		} else if strings.HasSuffix(rule.Path, "/" + Wildcard) && strings.HasPrefix(route + "/", strings.TrimSuffix(rule.Path, Wildcard)) {
+			rank = len(rule.Path) << 1
+		} else {
+			continue
+		}
+		if !anyMethod {
+			rank++
+		}
+		if rank > best {
+			matched, best = rule, rank
+		}
+	}
+	return matched
+}
@@ -0,0 +1,92 @@
+package rbac
+
+import "testing"
+
+func testEnforcer() *Enforcer {
+	e := New(nil)
+	e.SetPolicy(&Policy{
+		Roles: map[string]*Role{
+			"viewer": {Permissions: []string{"article:read"}},
+			"editor": {Inherits: []string{"viewer"}, Permissions: []string{"article:update:own", "comment:*"}},
+			"admin":  {Inherits: []string{"editor", "admin"}, Permissions: []string{"article:*"}},
+			"root":   {Permissions: []string{Wildcard}},
+		},
+		Routes: []*Rule{
+			{Path: "/v1/article/*", Permission: "article:read"},
+			{Method: "post", Path: "v1/article/*", Permission: "article:create"},
+			{Method: "*", Path: "/v1/article/update/:id", Permission: "article:update", Owner: "article"},
+			{Path: "/v1/article/update/:id/tags/*", Permission: "article:tag"},
+		},
+	})
+	return e
+}
+
+func TestCan(t *testing.T) {
+	e := testEnforcer()
+	cases := []struct {
+		roles      []string
+		permission string
+		want       bool
+	}{
+		{[]string{"viewer"}, "article:read", true},
+		{[]string{"viewer"}, "article:update", false},
+		{[]string{"editor"}, "article:read", true},
+		{[]string{"editor"}, "article:update", false},
+		{[]string{"editor"}, "article:update:own", true},
+		{[]string{"editor"}, "comment:delete", true},
+		{[]string{"editor"}, "comments:delete", false},
+		{[]string{"admin"}, "article:update", true},
+		{[]string{"admin"}, "article:update:own", true},
+		{[]string{"admin"}, "article", false},
+		{[]string{"admin"}, "comment:reply:delete", true},
+		{[]string{"root"}, "anything", true},
+		{[]string{"missing"}, "article:read", false},
+		{[]string{"missing", "viewer"}, "article:read", true},
+		{nil, "article:read", false},
+	}
+	for _, c := range cases {
+		if got := e.Can(c.roles, c.permission); got != c.want {
+			t.Errorf("Can(%v, %s) = %v, want %v", c.roles, c.permission, got, c.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	e := testEnforcer()
+	cases := []struct {
+		method, route, want string
+	}{
+		// 精确匹配优先于前缀匹配
+		{"GET", "/v1/article/update/:id", "article:update"},
+		// 前缀匹配取最长前缀
+		{"GET", "/v1/article/update/:id/tags/:tag", "article:tag"},
+		{"GET", "/v1/article/list", "article:read"},
+		{"GET", "/v1/article", "article:read"},
+		// 路径相同时指定方法的规则优先，方法不匹配时跳过
+		{"POST", "/v1/article/create", "article:create"},
+		{"POST", "/v1/article/update/:id", "article:update"},
+		{"GET", "/v1/articles", ""},
+		{"GET", "/v2/article/list", ""},
+	}
+	for _, c := range cases {
+		rule := e.Match(c.method, c.route)
+		got := ""
+		if rule != nil {
+			got = rule.Permission
+		}
+		if got != c.want {
+			t.Errorf("Match(%s, %s) = %q, want %q", c.method, c.route, got, c.want)
+		}
+	}
+}
+
+func TestLoaded(t *testing.T) {
+	e := New(nil)
+	if e.Loaded() {
+		t.Error("loaded before SetPolicy")
+	}
+	e.SetPolicy(&Policy{})
+	if !e.Loaded() {
+		t.Error("not loaded after SetPolicy")
+	}
+}
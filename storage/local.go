@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+	"path/filepath"
+)
+
+type LocalConfig struct {
+	Root    string `yaml:"root"`
+	BaseUrl string `yaml:"baseUrl"`
+}
+
+type localStorage struct {
+	root    string
+	baseUrl string
+}
+
+func NewLocal(cfg *LocalConfig) Storage {
+	return &localStorage{
+		root:    cfg.Root,
+		baseUrl: strings.TrimRight(cfg.BaseUrl, "/"),
+	}
+}
+
+func (s *localStorage) Put(key string, r io.Reader, size int64, contentType string) (*Object, error) {
+	file := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, r)
+	if err != nil {
+		os.Remove(file)
+		return nil, err
+	}
+	return &Object{
+		Key:         key,
+		Size:        n,
+		ContentType: contentType,
+		URL:         s.baseUrl + "/" + strings.TrimLeft(key, "/"),
+	}, nil
+}
+
+func (s *localStorage) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *localStorage) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+key)))
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"io"
+	"fmt"
+	"io/ioutil"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
+)
+
+const (
+	Local = "local"
+	S3    = "s3"
+)
+
+var (
+	storageCfg *StoConfig
+	Default    Storage
+	backends   = make(map[string]Storage)
+)
+
+type StoConfig struct {
+	Default string       `yaml:"default"`
+	Local   *LocalConfig `yaml:"local"`
+	S3      *S3Config    `yaml:"s3"`
+}
+
+// Object 已存储对象的元数据
+type Object struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag,omitempty"`
+	URL         string `json:"url"`
+}
+
+// Storage 文件存储后端
+type Storage interface {
+	Put(key string, r io.Reader, size int64, contentType string) (*Object, error)
+	Delete(key string) error
+}
+
+func Init(cfgFile string) error {
+	buf, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		return fmt.Errorf("%s文件读取失败：%v", cfgFile, err)
+	}
+	err = secret.Unmarshal(buf, &storageCfg)
+	if err != nil {
+		return fmt.Errorf("%s解析失败：%v", cfgFile, err)
+	}
+	InitStorage(storageCfg)
+	return nil
+}
+
+func InitStorage(cfg *StoConfig) {
+	if cfg == nil {
+		log.Error("storage config setting error")
+		return
+	}
+	if cfg.Local != nil {
+		Register(Local, NewLocal(cfg.Local))
+	}
+	if cfg.S3 != nil {
+		secret.Register(cfg.S3.SecretKey)
+		Register(S3, NewS3(cfg.S3))
+	}
+	if cfg.Default != "" {
+		if s, ok := backends[cfg.Default]; ok {
+			Default = s
+		} else {
+			log.Error(cfg.Default + " - Storage does not exist.")
+		}
+	}
+}
+
+// Register 注册存储后端，首个注册的后端作为默认后端
+func Register(name string, s Storage) {
+	backends[name] = s
+	if Default == nil {
+		Default = s
+	}
+}
+
+func Use(name string) Storage {
+	if s, ok := backends[name]; ok {
+		return s
+	}
+	log.Error(name + " - Storage does not exist.")
+	return nil
+}
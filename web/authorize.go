@@ -0,0 +1,123 @@
+package web
+
+import (
+	"sync"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/rbac"
+	"github.com/jinycoo/jinygo/errno"
+)
+
+const (
+	ContextKeySubject  = "jinygo.subject"
+	ContextKeyEnforcer = "jinygo.enforcer"
+)
+
+var owners = make(map[string]OwnerFunc)
+
+type (
+	// Subject 已认证的访问主体，由认证中间件通过 SetSubject 写入
+	Subject struct {
+		Id    string
+		Roles []string
+	}
+
+	// OwnerFunc 资源归属校验，返回当前主体是否为所访问资源的所有者
+	OwnerFunc func(ic *InContext) (bool, error)
+
+	// AuthorizeOption Enforcer 为空时使用 rbac.Default，DenyUnmapped 为 true 时拒绝未配置规则的路由
+	// Enforcer 未加载策略时拒绝全部请求
+	AuthorizeOption struct {
+		Enforcer     *rbac.Enforcer
+		DenyUnmapped bool
+	}
+)
+
+// SetSubject 设置当前请求的访问主体
+func SetSubject(c *gin.Context, subject *Subject) {
+	c.Set(ContextKeySubject, subject)
+}
+
+func (ic *InContext) Subject() *Subject {
+	if v, ok := ic.Ctx.Get(ContextKeySubject); ok {
+		if subject, ok := v.(*Subject); ok {
+			return subject
+		}
+	}
+	return nil
+}
+
+// Can 判断当前主体是否拥有权限，使用 Authorize 中间件的 Enforcer，未经过 Authorize 时使用 rbac.Default
+func (ic *InContext) Can(permission string) bool {
+	subject := ic.Subject()
+	if subject == nil {
+		return false
+	}
+	enforcer := rbac.Default
+	if v, ok := ic.Ctx.Get(ContextKeyEnforcer); ok {
+		if e, ok := v.(*rbac.Enforcer); ok {
+			enforcer = e
+		}
+	}
+	return enforcer.Can(subject.Roles, permission)
+}
+
+// RegisterOwner 注册资源归属校验回调，供 rbac 路由规则的 owner 引用
+func RegisterOwner(name string, fn OwnerFunc) {
+	owners[name] = fn
+}
+
+// Authorize 按 rbac 路由规则校验当前主体，需在认证中间件之后使用
+func Authorize(opt AuthorizeOption) gin.HandlerFunc {
+	var unloaded sync.Once
+	return func(c *gin.Context) {
+		enforcer := opt.Enforcer
+		if enforcer == nil {
+			enforcer = rbac.Default
+		}
+		if !enforcer.Loaded() {
+			unloaded.Do(func() {
+				log.Error("rbac 策略未加载，拒绝全部请求")
+			})
+			abortAuthorize(c, http.StatusForbidden, errno.ErrForbidden)
+			return
+		}
+		c.Set(ContextKeyEnforcer, enforcer)
+		rule := enforcer.Match(c.Request.Method, RoutePath(c))
+		if rule == nil {
+			if opt.DenyUnmapped {
+				abortAuthorize(c, http.StatusForbidden, errno.ErrForbidden)
+			}
+			return
+		}
+		ic := &InContext{Ctx: c}
+		subject := ic.Subject()
+		if subject == nil {
+			abortAuthorize(c, http.StatusUnauthorized, errno.ErrUnauthorized)
+			return
+		}
+		if rule.Permission == "" || enforcer.Can(subject.Roles, rule.Permission) {
+			return
+		}
+		if rule.Owner != "" && enforcer.Can(subject.Roles, rule.Permission + rbac.OwnSuffix) {
+			fn, ok := owners[rule.Owner]
+			if !ok {
+				log.Error(rule.Owner + " - Owner callback does not exist.")
+			} else if owned, err := fn(ic); err != nil {
+				log.Warn("资源归属校验失败：" + err.Error())
+			} else if owned {
+				return
+			}
+		}
+		abortAuthorize(c, http.StatusForbidden, errno.ErrForbidden)
+	}
+}
+
+func abortAuthorize(c *gin.Context, status, code int) {
+	c.AbortWithStatusJSON(status, &ApiResponse{
+		ErrCode: code,
+		Message: errno.ErrCode[code],
+		Data: gin.H{},
+	})
+}
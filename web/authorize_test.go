@@ -0,0 +1,83 @@
+package web
+
+import (
+	"errors"
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/rbac"
+)
+
+func TestAuthorize(t *testing.T) {
+	log.New(nil)
+	gin.SetMode(gin.TestMode)
+	enforcer := rbac.New(nil)
+	enforcer.SetPolicy(&rbac.Policy{
+		Roles: map[string]*rbac.Role{
+			"author": {Permissions: []string{"article:update:own"}},
+			"editor": {Permissions: []string{"article:*"}},
+		},
+		Routes: []*rbac.Rule{
+			{Path: "/article/:id", Permission: "article:update", Owner: "article"},
+		},
+	})
+	RegisterOwner("article", func(ic *InContext) (bool, error) {
+		switch ic.Ctx.Param("id") {
+		case "1":
+			return true, nil
+		case "err":
+			return false, errors.New("lookup failed")
+		}
+		return false, nil
+	})
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(ContextKeyRoute, "/article/:id")
+		if role := c.GetHeader("X-Role"); role != "" {
+			SetSubject(c, &Subject{Id: "u1", Roles: []string{role}})
+		}
+	}, Authorize(AuthorizeOption{Enforcer: enforcer}))
+	r.PUT("/article/:id", func(c *gin.Context) {
+		ic := &InContext{Ctx: c}
+		c.JSON(200, gin.H{"delete": ic.Can("article:delete")})
+	})
+
+	cases := []struct {
+		role, id string
+		status   int
+		body     string
+	}{
+		{"", "1", 401, ""},
+		{"author", "1", 200, `{"delete":false}`},
+		{"author", "2", 403, ""},
+		{"author", "err", 403, ""},
+		// Can 使用 AuthorizeOption.Enforcer 而非 rbac.Default
+		{"editor", "2", 200, `{"delete":true}`},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/article/" + c.id, nil)
+		req.Header.Set("X-Role", c.role)
+		r.ServeHTTP(w, req)
+		if w.Code != c.status || (c.body != "" && w.Body.String() != c.body) {
+			t.Errorf("%s /article/%s = %d %s", c.role, c.id, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAuthorizeUnloaded(t *testing.T) {
+	log.New(nil)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		SetSubject(c, &Subject{Id: "u1", Roles: []string{"admin"}})
+	}, Authorize(AuthorizeOption{Enforcer: rbac.New(nil)}))
+	r.GET("/open", func(c *gin.Context) { c.String(200, "ok") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/open", nil))
+	if w.Code != 403 {
+		t.Errorf("unloaded policy: status = %d, want 403", w.Code)
+	}
+}
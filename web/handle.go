@@ -3,9 +3,9 @@ package web
 import (
 	"strconv"
 	"io/ioutil"
+	"encoding/xml"
 	"github.com/gin-gonic/gin"
 	"github.com/json-iterator/go"
-	"github.com/jinycoo/jinygo/log"
 	"github.com/jinycoo/jinygo/errno"
 )
 
@@ -13,18 +13,23 @@ type InContext struct {
 	Ctx       *gin.Context
 	ApiRes    *ApiResponse
 	ApiOldRes *OldApiResponse
+	Page      *Pagination
 }
 
 type ApiResponse struct {
-	ErrCode  int `json:"msg_code"`
-	Message string `json:"message"`
-	Data    interface{} `json:"attachment"`
+	XMLName xml.Name `json:"-" xml:"response"`
+	ErrCode  int `json:"msg_code" xml:"msg_code"`
+	Message string `json:"message" xml:"message"`
+	Data    interface{} `json:"attachment" xml:"attachment"`
+	Pagination *Pagination `json:"pagination,omitempty" xml:"pagination,omitempty"`
 }
 
 type OldApiResponse struct {
-	ErrCode  int `json:"status"`
-	Message string `json:"message"`
-	Data    interface{} `json:"attachment"`
+	XMLName xml.Name `json:"-" xml:"response"`
+	ErrCode  int `json:"status" xml:"status"`
+	Message string `json:"message" xml:"message"`
+	Data    interface{} `json:"attachment" xml:"attachment"`
+	Pagination *Pagination `json:"pagination,omitempty" xml:"pagination,omitempty"`
 }
 
 func (ic *InContext) QueryInt(pkey string, def int) int {
@@ -66,30 +71,31 @@ func (ic *InContext) JsonPost() map[string]interface{} {
 	return params
 }
 
+// JsonResponse 输出响应，格式按 Accept 请求头协商，默认 JSON
 func (ic *InContext) JsonResponse() {
+	if ic.Page != nil {
+		ic.Page.buildLinks(ic.Ctx.Request.URL)
+	}
 	if ic.ApiOldRes != nil {
 		if ic.ApiOldRes.Data == nil {
 			ic.ApiOldRes.Data = gin.H{}
 		}
-		b, _ := jsoniter.Marshal(ic.ApiOldRes)
-		log.Info(string(b))
-		ic.Ctx.JSON(200, ic.ApiOldRes)
+		ic.ApiOldRes.Pagination = ic.Page
+		ic.Render(200, ic.ApiOldRes)
 	} else if ic.ApiRes != nil {
 		if ic.ApiRes.Data == nil {
 			ic.ApiRes.Data = gin.H{}
 		}
-		b, _ := jsoniter.Marshal(ic.ApiRes)
-		log.Info(string(b))
-		ic.Ctx.JSON(200, ic.ApiRes)
+		ic.ApiRes.Pagination = ic.Page
+		ic.Render(200, ic.ApiRes)
 	} else {
 		ic.ApiRes = &ApiResponse{
 			ErrCode: 0,
 			Message: errno.ErrCode[0],
 			Data: gin.H{},
+			Pagination: ic.Page,
 		}
-		b, _ := jsoniter.Marshal(ic.ApiRes)
-		log.Info(string(b))
-		ic.Ctx.JSON(200, ic.ApiRes)
+		ic.Render(200, ic.ApiRes)
 	}
 }
 /**
@@ -0,0 +1,254 @@
+package web
+
+import (
+	"sync"
+	"time"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/cache"
+)
+
+const (
+	DefaultHeartbeat   = 30 * time.Second
+	DefaultIdleTimeout = 90 * time.Second
+	connSendBuffer     = 64
+)
+
+var (
+	DefaultHub = NewHub()
+
+	ErrConnClosed = errors.New("connection closed")
+)
+
+type (
+	// StreamHandler 在 SSE/WebSocket 连接建立时调用，用于设置用户及订阅主题，返回错误则拒绝连接
+	StreamHandler func(c *gin.Context, conn *Conn) error
+
+	// Message 推送给客户端的消息，User 与 Topic 均为空时广播给所有连接
+	Message struct {
+		Event string      `json:"event,omitempty"`
+		User  string      `json:"user,omitempty"`
+		Topic string      `json:"topic,omitempty"`
+		Data  interface{} `json:"data"`
+	}
+
+	Conn struct {
+		User string
+
+		hub       *Hub
+		send      chan *Message
+		done      chan struct{}
+		once      sync.Once
+		topics    map[string]struct{}
+		onMessage func(conn *Conn, data []byte)
+	}
+
+	// Hub 管理长连接，支持按用户、按主题推送，启用 Redis 后消息可送达任意实例上的连接
+	// AllowOrigins 为允许建立 WebSocket 连接的来源，例如 https://example.com，为空时仅允许同源，* 允许任意来源
+	// IdleTimeout 为 SSE 未推送消息、WebSocket 未收到客户端数据时断开连接的时长，心跳不计入
+	Hub struct {
+		sync.RWMutex
+		Heartbeat    time.Duration
+		IdleTimeout  time.Duration
+		AllowOrigins []string
+
+		conns   map[*Conn]struct{}
+		users   map[string]map[*Conn]struct{}
+		topics  map[string]map[*Conn]struct{}
+		channel string
+		pubsub  *redis.PubSub
+	}
+)
+
+func NewHub() *Hub {
+	return &Hub{
+		Heartbeat:   DefaultHeartbeat,
+		IdleTimeout: DefaultIdleTimeout,
+		conns:       make(map[*Conn]struct{}),
+		users:       make(map[string]map[*Conn]struct{}),
+		topics:      make(map[string]map[*Conn]struct{}),
+	}
+}
+
+// EnableRedis 通过 cache.RCache 的 pub/sub 在多实例间分发消息
+func (h *Hub) EnableRedis(channel string) error {
+	if cache.RCache == nil {
+		return errors.New("redis cache not initialized")
+	}
+	pubsub := cache.RCache.Subscribe(channel)
+	if _, err := pubsub.Receive(); err != nil {
+		pubsub.Close()
+		return err
+	}
+	h.Lock()
+	h.channel = channel
+	h.pubsub = pubsub
+	h.Unlock()
+	go func() {
+		for m := range pubsub.Channel() {
+			var msg Message
+			if err := jsoniter.UnmarshalFromString(m.Payload, &msg); err != nil {
+				log.Warn("hub 消息解析失败：" + err.Error())
+				continue
+			}
+			h.deliver(&msg)
+		}
+	}()
+	return nil
+}
+
+func (h *Hub) SendToUser(user string, event string, data interface{}) error {
+	return h.dispatch(&Message{Event: event, User: user, Data: data})
+}
+
+func (h *Hub) Publish(topic string, event string, data interface{}) error {
+	return h.dispatch(&Message{Event: event, Topic: topic, Data: data})
+}
+
+func (h *Hub) Broadcast(event string, data interface{}) error {
+	return h.dispatch(&Message{Event: event, Data: data})
+}
+
+// Count 返回当前实例上的连接数
+func (h *Hub) Count() int {
+	h.RLock()
+	defer h.RUnlock()
+	return len(h.conns)
+}
+
+func (h *Hub) dispatch(msg *Message) error {
+	h.RLock()
+	channel := h.channel
+	h.RUnlock()
+	if channel == "" {
+		h.deliver(msg)
+		return nil
+	}
+	b, err := jsoniter.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return cache.RCache.Publish(channel, b).Err()
+}
+
+func (h *Hub) deliver(msg *Message) {
+	h.RLock()
+	var targets map[*Conn]struct{}
+	switch {
+	case msg.User != "":
+		targets = h.users[msg.User]
+	case msg.Topic != "":
+		targets = h.topics[msg.Topic]
+	default:
+		targets = h.conns
+	}
+	conns := make([]*Conn, 0, len(targets))
+	for c := range targets {
+		conns = append(conns, c)
+	}
+	h.RUnlock()
+	for _, c := range conns {
+		c.Send(msg)
+	}
+}
+
+func (h *Hub) newConn() *Conn {
+	return &Conn{
+		hub:    h,
+		send:   make(chan *Message, connSendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+}
+
+func (h *Hub) register(c *Conn) {
+	h.Lock()
+	defer h.Unlock()
+	h.conns[c] = struct{}{}
+	if c.User != "" {
+		addConn(h.users, c.User, c)
+	}
+	for t := range c.topics {
+		addConn(h.topics, t, c)
+	}
+}
+
+func (h *Hub) unregister(c *Conn) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.conns, c)
+	if c.User != "" {
+		removeConn(h.users, c.User, c)
+	}
+	for t := range c.topics {
+		removeConn(h.topics, t, c)
+	}
+}
+
+func addConn(index map[string]map[*Conn]struct{}, key string, c *Conn) {
+	if _, ok := index[key]; !ok {
+		index[key] = make(map[*Conn]struct{})
+	}
+	index[key][c] = struct{}{}
+}
+
+func removeConn(index map[string]map[*Conn]struct{}, key string, c *Conn) {
+	if conns, ok := index[key]; ok {
+		delete(conns, c)
+		if len(conns) == 0 {
+			delete(index, key)
+		}
+	}
+}
+
+// Subscribe 订阅主题，可在 StreamHandler 中或连接建立后调用
+func (c *Conn) Subscribe(topics ...string) {
+	c.hub.Lock()
+	defer c.hub.Unlock()
+	_, registered := c.hub.conns[c]
+	for _, t := range topics {
+		c.topics[t] = struct{}{}
+		if registered {
+			addConn(c.hub.topics, t, c)
+		}
+	}
+}
+
+func (c *Conn) Unsubscribe(topics ...string) {
+	c.hub.Lock()
+	defer c.hub.Unlock()
+	for _, t := range topics {
+		delete(c.topics, t)
+		removeConn(c.hub.topics, t, c)
+	}
+}
+
+// OnMessage 设置 WebSocket 客户端消息处理函数，SSE 连接不会调用
+func (c *Conn) OnMessage(fn func(conn *Conn, data []byte)) {
+	c.onMessage = fn
+}
+
+// Send 向当前连接推送消息，发送缓冲区已满时关闭该连接
+func (c *Conn) Send(msg *Message) error {
+	select {
+	case <-c.done:
+		return ErrConnClosed
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return nil
+	default:
+		c.Close()
+		return ErrConnClosed
+	}
+}
+
+func (c *Conn) Close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
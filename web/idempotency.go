@@ -0,0 +1,189 @@
+package web
+
+import (
+	"time"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/cache"
+	"github.com/jinycoo/jinygo/errno"
+	"github.com/jinycoo/jinygo/constants"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	DefaultIdempotencyTTL = 24 * time.Hour
+	DefaultIdempotencyLockTTL = time.Minute
+	DefaultIdempotencyMaxBody = 10 << 20
+
+	idempotencyPrefix = "jinygo:idem:"
+)
+
+// releaseLock 锁的值与持有者一致时删除
+var releaseLock = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+
+// IdempotencyOption 幂等配置，TTL 为响应保存时间，LockTTL 为首个请求处理期间的锁定时间
+// MaxBody 为计算摘要时读取的请求体上限，默认 10MB；Required 为 true 时缺少 Idempotency-Key 的请求将被拒绝
+type IdempotencyOption struct {
+	TTL      time.Duration
+	LockTTL  time.Duration
+	MaxBody  int64
+	Required bool
+}
+
+type idempotentResponse struct {
+	BodyHash string              `json:"body_hash"`
+	Status   int                 `json:"status"`
+	Header   map[string][]string `json:"header"`
+	Body     []byte              `json:"body"`
+}
+
+type teeWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency 为 POST/PUT/PATCH 请求提供 Idempotency-Key 支持
+// 相同 key 与相同请求体的重试将返回首次请求的响应，请求体不同时返回冲突错误
+func Idempotency(opt IdempotencyOption) gin.HandlerFunc {
+	if opt.TTL <= 0 {
+		opt.TTL = DefaultIdempotencyTTL
+	}
+	if opt.LockTTL <= 0 {
+		opt.LockTTL = DefaultIdempotencyLockTTL
+	}
+	if opt.MaxBody <= 0 {
+		opt.MaxBody = DefaultIdempotencyMaxBody
+	}
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case constants.MethodPost, constants.MethodPut, constants.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" || cache.RCache == nil {
+			if key == "" && opt.Required {
+				abortIdempotency(c, http.StatusBadRequest, errno.ErrIdemMissing)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, opt.MaxBody))
+			if err != nil {
+				abortIdempotency(c, http.StatusRequestEntityTooLarge, errno.ErrIdemTooLarge)
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		sum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(sum[:])
+		recordKey := idempotencyPrefix + c.Request.Method + ":" + RoutePath(c) + ":" + key
+		lockKey := recordKey + ":lock"
+
+		if replayIdempotent(c, recordKey, bodyHash) {
+			return
+		}
+
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			log.Warn("幂等锁标识生成失败：" + err.Error())
+			c.Next()
+			return
+		}
+		owner := hex.EncodeToString(token)
+		locked, err := cache.RCache.SetNX(lockKey, owner, opt.LockTTL).Result()
+		if err != nil {
+			log.Warn("幂等锁获取失败：" + err.Error())
+			c.Next()
+			return
+		}
+		if !locked {
+			abortIdempotency(c, http.StatusConflict, errno.ErrIdemProcessing)
+			return
+		}
+		// 仅释放自己持有的锁，处理时间超过 LockTTL 时锁可能已被其他请求持有
+		defer releaseLock.Run(cache.RCache, []string{lockKey}, owner)
+		// 获取锁前首个请求可能刚好保存了响应，获取锁后再次检查
+		if replayIdempotent(c, recordKey, bodyHash) {
+			return
+		}
+
+		writer := &teeWriter{ResponseWriter: c.Writer, body: new(bytes.Buffer)}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		// 5xx 响应不保存，允许客户端重试
+		if status := writer.Status(); status < 500 {
+			res := &idempotentResponse{
+				BodyHash: bodyHash,
+				Status:   status,
+				Header:   map[string][]string{"Content-Type": writer.Header()["Content-Type"]},
+				Body:     writer.body.Bytes(),
+			}
+			if buf, err := jsoniter.Marshal(res); err == nil {
+				if err := cache.RCache.Set(recordKey, buf, opt.TTL).Err(); err != nil {
+					log.Warn("幂等响应保存失败：" + err.Error())
+				}
+			}
+		}
+	}
+}
+
+// replayIdempotent 存在已保存的响应时回放，请求体不同时返回冲突错误
+func replayIdempotent(c *gin.Context, recordKey, bodyHash string) bool {
+	buf, err := cache.RCache.Get(recordKey).Bytes()
+	if err != nil {
+		return false
+	}
+	var res idempotentResponse
+	if err := jsoniter.Unmarshal(buf, &res); err != nil {
+		return false
+	}
+	if res.BodyHash != bodyHash {
+		abortIdempotency(c, http.StatusUnprocessableEntity, errno.ErrIdemMismatch)
+		return true
+	}
+	for k, v := range res.Header {
+		c.Writer.Header()[k] = v
+	}
+	c.Header(HeaderIdempotentReplayed, "true")
+	c.Writer.WriteHeader(res.Status)
+	c.Writer.Write(res.Body)
+	c.Abort()
+	return true
+}
+
+func abortIdempotency(c *gin.Context, status, code int) {
+	c.AbortWithStatusJSON(status, &ApiResponse{
+		ErrCode: code,
+		Message: errno.ErrCode[code],
+		Data: gin.H{},
+	})
+}
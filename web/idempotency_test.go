@@ -0,0 +1,87 @@
+package web
+
+import (
+	"strings"
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/alicebob/miniredis"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/cache"
+)
+
+func TestIdempotency(t *testing.T) {
+	log.New(nil)
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	saved := cache.RCache
+	cache.RCache = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { cache.RCache = saved }()
+
+	calls := 0
+	r := gin.New()
+	r.Use(Idempotency(IdempotencyOption{}))
+	r.POST("/orders", func(c *gin.Context) {
+		calls++
+		// 模拟处理超过 LockTTL 后锁被其他请求持有
+		mr.Set(idempotencyPrefix + "POST:/orders:k1:lock", "other")
+		c.JSON(201, gin.H{"n": calls})
+	})
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set(HeaderIdempotencyKey, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("k1", "a"); w.Code != 201 {
+		t.Fatalf("first: %d", w.Code)
+	}
+	if v, _ := mr.Get(idempotencyPrefix + "POST:/orders:k1:lock"); v != "other" {
+		t.Fatalf("lock held by another request was released: %q", v)
+	}
+	mr.Del(idempotencyPrefix + "POST:/orders:k1:lock")
+	w := send("k1", "a")
+	if w.Code != 201 || w.Header().Get(HeaderIdempotentReplayed) != "true" || calls != 1 {
+		t.Fatalf("replay: %d %q calls=%d", w.Code, w.Header().Get(HeaderIdempotentReplayed), calls)
+	}
+	if w := send("k1", "b"); w.Code != 422 {
+		t.Fatalf("mismatch: %d", w.Code)
+	}
+	mr.Set(idempotencyPrefix + "POST:/orders:k2:lock", "other")
+	if w := send("k2", "a"); w.Code != 409 {
+		t.Fatalf("locked: %d", w.Code)
+	}
+}
+
+func TestIdempotencyMaxBody(t *testing.T) {
+	log.New(nil)
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	saved := cache.RCache
+	cache.RCache = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { cache.RCache = saved }()
+
+	r := gin.New()
+	r.Use(Idempotency(IdempotencyOption{MaxBody: 4}))
+	r.POST("/orders", func(c *gin.Context) {
+		c.JSON(201, gin.H{})
+	})
+	for body, status := range map[string]int{"abcd": 201, "abcde": 413} {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set(HeaderIdempotencyKey, body)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("%s: got %d, want %d", body, w.Code, status)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"net/url"
+	"net/http"
+	"encoding/xml"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/errno"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	QueryPage   = "page"
+	QuerySize   = "size"
+	QueryOffset = "offset"
+	QueryCursor = "cursor"
+)
+
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// Pagination 分页信息，由 JsonResponse 输出到响应的 pagination 字段
+type Pagination struct {
+	Page       int       `json:"page,omitempty" xml:"page,omitempty"`
+	Size       int       `json:"size" xml:"size"`
+	Total      int64     `json:"total" xml:"total"`
+	NextCursor string    `json:"next_cursor,omitempty" xml:"next_cursor,omitempty"`
+	Links      PageLinks `json:"links,omitempty" xml:"links,omitempty"`
+
+	offset       int
+	cursor       interface{}
+	cursorColumn string
+}
+
+// Paginate 解析 page/size（或 offset/size）分页参数，size 超过 maxSize 时取 maxSize
+func (ic *InContext) Paginate(maxSize int) *Pagination {
+	p := &Pagination{Size: ic.pageSize(maxSize)}
+	if offset := ic.QueryInt(QueryOffset, -1); offset >= 0 {
+		p.offset = offset
+		p.Page = offset/p.Size + 1
+	} else {
+		p.Page = ic.QueryInt(QueryPage, 1)
+		if p.Page < 1 {
+			p.Page = 1
+		}
+		p.offset = (p.Page - 1) * p.Size
+	}
+	ic.Page = p
+	return p
+}
+
+// CursorPaginate 解析 cursor/size 游标分页参数，column 为游标对应的有序唯一列
+// 游标无效时以 400 响应并返回 ErrInvalidCursor，处理函数应直接返回
+func (ic *InContext) CursorPaginate(column string, maxSize int) (*Pagination, error) {
+	p := &Pagination{Size: ic.pageSize(maxSize), cursorColumn: column}
+	if cursor := ic.QueryString(QueryCursor, ""); cursor != "" {
+		v, err := decodeCursor(cursor)
+		if err != nil {
+			ic.Ctx.AbortWithStatusJSON(http.StatusBadRequest, &ApiResponse{
+				ErrCode: errno.ErrPageCursor,
+				Message: errno.ErrCode[errno.ErrPageCursor],
+				Data: gin.H{},
+			})
+			return nil, ErrInvalidCursor
+		}
+		p.cursor = v
+	}
+	ic.Page = p
+	return p, nil
+}
+
+func (ic *InContext) pageSize(maxSize int) int {
+	if maxSize <= 0 {
+		maxSize = MaxPageSize
+	}
+	size := ic.QueryInt(QuerySize, DefaultPageSize)
+	if size < 1 {
+		size = DefaultPageSize
+	}
+	if size > maxSize {
+		size = maxSize
+	}
+	return size
+}
+
+func (p *Pagination) PageLimit() int {
+	return p.Size
+}
+
+func (p *Pagination) PageOffset() int {
+	return p.offset
+}
+
+func (p *Pagination) PageCursor() (column string, value interface{}, ok bool) {
+	return p.cursorColumn, p.cursor, p.cursorColumn != ""
+}
+
+func (p *Pagination) SetTotal(total int64) {
+	p.Total = total
+}
+
+// SetNextCursor 设置下一页游标，value 为 nil 时表示没有下一页
+func (p *Pagination) SetNextCursor(value interface{}) error {
+	cursor, err := encodeCursor(value)
+	if err != nil {
+		return err
+	}
+	p.NextCursor = cursor
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// encodeCursor 按类型编码游标值，格式为 <类型>:<值>，时间使用 RFC3339Nano 以保留纳秒及时区
+func encodeCursor(value interface{}) (string, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	var s string
+	switch {
+	case !v.IsValid():
+		return "", nil
+	case v.Type().ConvertibleTo(timeType) && v.Kind() == reflect.Struct:
+		s = "t:" + v.Convert(timeType).Interface().(time.Time).Format(time.RFC3339Nano)
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		s = "i:" + strconv.FormatInt(v.Int(), 10)
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		s = "u:" + strconv.FormatUint(v.Uint(), 10)
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		s = "f:" + strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case v.Kind() == reflect.String:
+		s = "s:" + v.String()
+	default:
+		return "", fmt.Errorf("web: unsupported cursor type %T", value)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(s)), nil
+}
+
+func decodeCursor(cursor string) (interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return nil, ErrInvalidCursor
+	}
+	kind, value := s[:i], s[i+1:]
+	switch kind {
+	case "t":
+		return time.Parse(time.RFC3339Nano, value)
+	case "i":
+		return strconv.ParseInt(value, 10, 64)
+	case "u":
+		return strconv.ParseUint(value, 10, 64)
+	case "f":
+		return strconv.ParseFloat(value, 64)
+	case "s":
+		return value, nil
+	}
+	return nil, ErrInvalidCursor
+}
+
+func (p *Pagination) buildLinks(u *url.URL) {
+	size := strconv.Itoa(p.Size)
+	p.Links = PageLinks{"self": u.RequestURI()}
+	if p.cursorColumn != "" {
+		if p.NextCursor != "" {
+			p.Links["next"] = pageURL(u, map[string]string{QueryCursor: p.NextCursor, QuerySize: size})
+		}
+		return
+	}
+	last := 1
+	if p.Total > 0 {
+		last = int((p.Total + int64(p.Size) - 1) / int64(p.Size))
+	}
+	page := func(n int) string {
+		return pageURL(u, map[string]string{QueryPage: strconv.Itoa(n), QuerySize: size}, QueryOffset)
+	}
+	p.Links["first"] = page(1)
+	p.Links["last"] = page(last)
+	if p.Page > 1 {
+		p.Links["prev"] = page(p.Page - 1)
+	}
+	if p.Page < last {
+		p.Links["next"] = page(p.Page + 1)
+	}
+}
+
+func pageURL(u *url.URL, set map[string]string, del ...string) string {
+	ru := *u
+	query := ru.Query()
+	for _, k := range del {
+		query.Del(k)
+	}
+	for k, v := range set {
+		query.Set(k, v)
+	}
+	ru.RawQuery = query.Encode()
+	return ru.RequestURI()
+}
+
+// PageLinks 分页链接，XML 输出为 <link rel="next">...</link> 形式
+type PageLinks map[string]string
+
+func (l PageLinks) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	rels := make([]string, 0, len(l))
+	for rel := range l {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		elem := xml.StartElement{Name: xml.Name{Local: "link"}, Attr: []xml.Attr{{Name: xml.Name{Local: "rel"}, Value: rel}}}
+		if err := e.EncodeElement(l[rel], elem); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"time"
+	"testing"
+	"net/http/httptest"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 8, 30, 0, 123456789, time.FixedZone("CST", 8*3600))
+	id := int64(42)
+	for _, v := range []interface{}{at, int64(-7), uint32(7), 1.25, "a:b", &id} {
+		cursor, err := encodeCursor(v)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		got, err := decodeCursor(cursor)
+		if err != nil {
+			t.Fatalf("%v: %v", v, err)
+		}
+		switch want := v.(type) {
+		case time.Time:
+			if tm, ok := got.(time.Time); !ok || !tm.Equal(want) {
+				t.Errorf("time cursor = %v, want %v", got, want)
+			}
+		case uint32:
+			if got != uint64(want) {
+				t.Errorf("uint cursor = %v", got)
+			}
+		case *int64:
+			if got != *want {
+				t.Errorf("pointer cursor = %v", got)
+			}
+		default:
+			if got != v {
+				t.Errorf("cursor = %#v, want %#v", got, v)
+			}
+		}
+	}
+	if cursor, err := encodeCursor(nil); err != nil || cursor != "" {
+		t.Errorf("nil cursor = %q, %v", cursor, err)
+	}
+	if _, err := encodeCursor([]int{1}); err == nil {
+		t.Error("slice cursor should fail")
+	}
+}
+
+func TestCursorPaginateInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		ic := &InContext{Ctx: c}
+		if _, err := ic.CursorPaginate("id", 0); err != nil {
+			return
+		}
+		c.String(200, "ok")
+	})
+	enc := base64.RawURLEncoding.EncodeToString
+	cases := []struct {
+		cursor string
+		status int
+	}{
+		{"", 200},
+		{enc([]byte("i:10")), 200},
+		{"!!", 400},
+		{enc([]byte("10")), 400},
+		{enc([]byte("i:abc")), 400},
+		{enc([]byte("t:2026-03-01")), 400},
+		{enc([]byte("x:1")), 400},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/?cursor="+c.cursor, nil))
+		if w.Code != c.status {
+			t.Errorf("cursor %q: got %d %s", c.cursor, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package web
+
+import (
+	"io"
+	"net"
+	"path"
+	"sync"
+	"time"
+	"errors"
+	"context"
+	"strings"
+	"sync/atomic"
+	"net/url"
+	"net/http"
+	"net/http/httputil"
+	"github.com/gin-gonic/gin"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/errno"
+	"github.com/jinycoo/jinygo/constants"
+)
+
+const (
+	ContextKeyUpstream = "jinygo.upstream"
+
+	DefaultProxyTimeout     = 30 * time.Second
+	DefaultProxyMaxFails    = 3
+	DefaultProxyFailTimeout = 10 * time.Second
+
+	proxyPathParam = "proxypath"
+)
+
+var errProxyBodyIdle = errors.New("web: proxy upstream response body idle timeout")
+
+var proxyMethods = []string{
+	constants.MethodGet,
+	constants.MethodPost,
+	constants.MethodPut,
+	constants.MethodPatch,
+	constants.MethodDelete,
+	constants.MethodHead,
+	constants.MethodOptions,
+}
+
+type (
+	// ProxyOption 反向代理配置
+	// StripPrefix 为 true 时转发前去掉 prefix，Rewrite 可进一步改写转发路径
+	// 上游在 FailTimeout 内连续失败 MaxFails 次（连接错误或 502/503/504）后，在 FailTimeout 内不再被选中
+	// Timeout 为等待上游响应头的时间；IdleTimeout 为读取响应体时两次收到数据的最长间隔，默认同 Timeout，
+	// 超过后断开连接，SSE 等长连接上游需在该间隔内发送数据或心跳，WebSocket 透传不受限制
+	ProxyOption struct {
+		StripPrefix     bool
+		Rewrite         func(path string) string
+		SetHeaders      map[string]string
+		RemoveHeaders   []string
+		ResponseHeaders map[string]string
+		Timeout         time.Duration
+		IdleTimeout     time.Duration
+		DialTimeout     time.Duration
+		MaxFails        int
+		FailTimeout     time.Duration
+	}
+
+	// ReverseProxy 轮询转发至多个上游的反向代理，支持 WebSocket 透传
+	ReverseProxy struct {
+		prefix    string
+		option    ProxyOption
+		upstreams []*upstream
+		proxy     *httputil.ReverseProxy
+		mu        sync.Mutex
+		next      int
+	}
+
+	upstream struct {
+		target    *url.URL
+		fails     int
+		firstFail time.Time
+		downUntil time.Time
+	}
+
+	upstreamKey struct{}
+
+	// idleBody 超过 timeout 未收到数据时关闭上游响应体，使转发中止而不是一直挂起
+	idleBody struct {
+		io.ReadCloser
+		timeout time.Duration
+		timer   *time.Timer
+		expired int32
+	}
+
+	// detachedContext 保留请求上下文中的值但不继承截止时间，用于长连接的 WebSocket 透传
+	detachedContext struct {
+		context.Context
+	}
+)
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// Proxy 将 prefix 下的全部请求转发至 upstreams，访问日志由 incLogger 记录并附带 upstream 字段
+func (g *RuGroup) Proxy(prefix string, upstreams []string, opt ProxyOption) *ReverseProxy {
+	p := NewReverseProxy(path.Join(g.Name, prefix), upstreams, opt)
+	prefix = strings.Trim(prefix, constants.Separator)
+	for _, method := range proxyMethods {
+		g.addStream(method, prefix, p.Handle)
+		g.addStream(method, prefix + "/*" + proxyPathParam, p.Handle)
+	}
+	return p
+}
+
+func NewReverseProxy(prefix string, upstreams []string, opt ProxyOption) *ReverseProxy {
+	if opt.Timeout <= 0 {
+		opt.Timeout = DefaultProxyTimeout
+	}
+	if opt.IdleTimeout <= 0 {
+		opt.IdleTimeout = opt.Timeout
+	}
+	if opt.DialTimeout <= 0 {
+		opt.DialTimeout = opt.Timeout
+	}
+	if opt.MaxFails <= 0 {
+		opt.MaxFails = DefaultProxyMaxFails
+	}
+	if opt.FailTimeout <= 0 {
+		opt.FailTimeout = DefaultProxyFailTimeout
+	}
+	p := &ReverseProxy{prefix: prefix, option: opt}
+	for _, u := range upstreams {
+		target, err := url.Parse(u)
+		if err != nil || target.Host == "" {
+			log.Error(u + " - Proxy upstream is invalid.")
+			continue
+		}
+		p.upstreams = append(p.upstreams, &upstream{target: target})
+	}
+	p.proxy = &httputil.ReverseProxy{
+		Director:       p.director,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.errorHandler,
+		FlushInterval:  100 * time.Millisecond,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: opt.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   32,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   opt.DialTimeout,
+			ResponseHeaderTimeout: opt.Timeout,
+		},
+	}
+	return p
+}
+
+func (p *ReverseProxy) Handle(c *gin.Context) {
+	u := p.pick()
+	if u == nil {
+		abortBadGateway(c)
+		return
+	}
+	c.Set(ContextKeyUpstream, u.target.Host)
+	ctx := context.WithValue(c.Request.Context(), upstreamKey{}, u)
+	if isUpgrade(c.Request) {
+		ctx = detachedContext{ctx}
+	}
+	req := c.Request.WithContext(ctx)
+	if id := RequestId(c); id != "" {
+		req.Header.Set(HeaderRequestId, id)
+	}
+	p.proxy.ServeHTTP(c.Writer, req)
+}
+
+// pick 轮询选择可用上游，全部不可用时选择最早恢复的上游
+func (p *ReverseProxy) pick() *upstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.upstreams) == 0 {
+		return nil
+	}
+	now := time.Now()
+	var fallback *upstream
+	for i := 0; i < len(p.upstreams); i++ {
+		u := p.upstreams[(p.next + i) % len(p.upstreams)]
+		if now.After(u.downUntil) {
+			p.next = (p.next + i + 1) % len(p.upstreams)
+			return u
+		}
+		if fallback == nil || u.downUntil.Before(fallback.downUntil) {
+			fallback = u
+		}
+	}
+	return fallback
+}
+
+func (p *ReverseProxy) director(req *http.Request) {
+	u, _ := req.Context().Value(upstreamKey{}).(*upstream)
+	if u == nil {
+		return
+	}
+	reqPath := req.URL.Path
+	if p.option.StripPrefix {
+		reqPath = constants.Separator + strings.TrimLeft(strings.TrimPrefix(reqPath, p.prefix), constants.Separator)
+	}
+	if p.option.Rewrite != nil {
+		reqPath = p.option.Rewrite(reqPath)
+	}
+	req.URL.Scheme = u.target.Scheme
+	req.URL.Host = u.target.Host
+	req.URL.Path = strings.TrimRight(u.target.Path, constants.Separator) + reqPath
+	req.URL.RawPath = ""
+	if u.target.RawQuery != "" {
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = u.target.RawQuery
+		} else {
+			req.URL.RawQuery = u.target.RawQuery + "&" + req.URL.RawQuery
+		}
+	}
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	if req.TLS != nil {
+		req.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		req.Header.Set("X-Forwarded-Proto", "http")
+	}
+	req.Host = u.target.Host
+	for _, k := range p.option.RemoveHeaders {
+		req.Header.Del(k)
+	}
+	for k, v := range p.option.SetHeaders {
+		req.Header.Set(k, v)
+	}
+}
+
+func (p *ReverseProxy) modifyResponse(res *http.Response) error {
+	u, _ := res.Request.Context().Value(upstreamKey{}).(*upstream)
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		p.fail(u)
+	default:
+		p.success(u)
+	}
+	for k, v := range p.option.ResponseHeaders {
+		res.Header.Set(k, v)
+	}
+	// 101 响应体为双向连接，由 ReverseProxy 直接透传
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		res.Body = newIdleBody(res.Body, p.option.IdleTimeout)
+	}
+	return nil
+}
+
+func (p *ReverseProxy) errorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	u, _ := req.Context().Value(upstreamKey{}).(*upstream)
+	if req.Context().Err() == context.Canceled {
+		return
+	}
+	p.fail(u)
+	fields := map[string]interface{}{
+		"prefix": p.prefix,
+		"method": req.Method,
+		"url":    req.URL.String(),
+		"error":  err.Error(),
+	}
+	if id := req.Header.Get(HeaderRequestId); id != "" {
+		fields["request_id"] = id
+	}
+	log.CWarn("Proxy upstream error", fields)
+	if c, ok := w.(gin.ResponseWriter); ok && c.Written() {
+		return
+	}
+	status, code := http.StatusBadGateway, errno.ErrBadGateway
+	if ne, ok := err.(net.Error); ok && ne.Timeout() || err == context.DeadlineExceeded {
+		status, code = http.StatusGatewayTimeout, errno.ErrTimeout
+	}
+	body, _ := jsoniter.Marshal(&ApiResponse{ErrCode: code, Message: errno.ErrCode[code], Data: gin.H{}})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (p *ReverseProxy) fail(u *upstream) {
+	if u == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if u.fails == 0 || now.Sub(u.firstFail) > p.option.FailTimeout {
+		u.fails = 0
+		u.firstFail = now
+	}
+	u.fails++
+	if u.fails >= p.option.MaxFails {
+		u.downUntil = now.Add(p.option.FailTimeout)
+		u.fails = 0
+		log.Warn(u.target.Host + " 上游服务连续失败，暂停转发")
+	}
+}
+
+func (p *ReverseProxy) success(u *upstream) {
+	if u == nil {
+		return
+	}
+	p.mu.Lock()
+	u.fails = 0
+	p.mu.Unlock()
+}
+
+func newIdleBody(body io.ReadCloser, timeout time.Duration) *idleBody {
+	b := &idleBody{ReadCloser: body, timeout: timeout}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.expired, 1)
+		body.Close()
+	})
+	return b
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if atomic.LoadInt32(&b.expired) == 1 {
+		return n, errProxyBodyIdle
+	}
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
+
+func abortBadGateway(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusBadGateway, &ApiResponse{
+		ErrCode: errno.ErrBadGateway,
+		Message: errno.ErrCode[errno.ErrBadGateway],
+		Data: gin.H{},
+	})
+}
+
+func isUpgrade(req *http.Request) bool {
+	return strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade") && req.Header.Get("Upgrade") != ""
+}
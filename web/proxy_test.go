@@ -0,0 +1,168 @@
+package web
+
+import (
+	"time"
+	"testing"
+	"net/http"
+	"io/ioutil"
+	"sync/atomic"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/errno"
+)
+
+// proxyServer 启动转发至 upstreams 的代理，ReverseProxy 需要支持 CloseNotify 的 ResponseWriter
+func proxyServer(upstreams []string, opt ProxyOption) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	p := NewReverseProxy("/v1/api", upstreams, opt)
+	r := gin.New()
+	r.Use(Recovery())
+	r.Any("/v1/api/*" + proxyPathParam, p.Handle)
+	return httptest.NewServer(r)
+}
+
+type proxyResult struct {
+	Code int
+	Body string
+	Err  error
+}
+
+func proxyGet(srv *httptest.Server, target string) proxyResult {
+	res, err := http.Get(srv.URL + target)
+	if err != nil {
+		return proxyResult{Err: err}
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	return proxyResult{Code: res.StatusCode, Body: string(body), Err: err}
+}
+
+func TestProxyRewrite(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer upstream.Close()
+	cases := []struct {
+		opt  ProxyOption
+		want string
+	}{
+		{ProxyOption{}, "/base/v1/api/users?id=1"},
+		{ProxyOption{StripPrefix: true}, "/base/users?id=1"},
+		{ProxyOption{StripPrefix: true, Rewrite: func(p string) string { return "/v2" + p }}, "/base/v2/users?id=1"},
+	}
+	for _, c := range cases {
+		srv := proxyServer([]string{upstream.URL + "/base"}, c.opt)
+		w := proxyGet(srv, "/v1/api/users?id=1")
+		srv.Close()
+		if w.Code != 200 || w.Body != c.want {
+			t.Errorf("got %d %s, want %s", w.Code, w.Body, c.want)
+		}
+	}
+}
+
+func TestProxyBalance(t *testing.T) {
+	log.New(nil)
+	var bDown int32 = 1
+	a := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a"))
+	}))
+	defer a.Close()
+	b := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&bDown) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("b"))
+	}))
+	defer b.Close()
+	r := proxyServer([]string{a.URL, b.URL}, ProxyOption{MaxFails: 2, FailTimeout: 100 * time.Millisecond})
+	defer r.Close()
+
+	got := ""
+	for i := 0; i < 8; i++ {
+		w := proxyGet(r, "/v1/api/")
+		if w.Code == 200 {
+			got += w.Body
+		} else {
+			got += "-"
+		}
+	}
+	// b 连续失败 2 次后在 FailTimeout 内不再被选中
+	if got != "a-a-aaaa" {
+		t.Errorf("balance = %s, want a-a-aaaa", got)
+	}
+
+	atomic.StoreInt32(&bDown, 0)
+	time.Sleep(150 * time.Millisecond)
+	got = ""
+	for i := 0; i < 4; i++ {
+		got += proxyGet(r, "/v1/api/").Body
+	}
+	if got != "abab" && got != "baba" {
+		t.Errorf("after recovery = %s, want round robin", got)
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+	log.New(nil)
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	cases := []struct {
+		upstream string
+		status   int
+		code     int
+	}{
+		{down.URL, http.StatusBadGateway, errno.ErrBadGateway},
+		{slow.URL, http.StatusGatewayTimeout, errno.ErrTimeout},
+	}
+	for _, c := range cases {
+		srv := proxyServer([]string{c.upstream}, ProxyOption{Timeout: 50 * time.Millisecond})
+		w := proxyGet(srv, "/v1/api/")
+		srv.Close()
+		var res ApiResponse
+		jsoniter.Unmarshal([]byte(w.Body), &res)
+		if w.Code != c.status || res.ErrCode != c.code || res.Message != errno.ErrCode[c.code] {
+			t.Errorf("%s: got %d %s", c.upstream, w.Code, w.Body)
+		}
+	}
+	srv := proxyServer(nil, ProxyOption{})
+	defer srv.Close()
+	if w := proxyGet(srv, "/v1/api/"); w.Code != http.StatusBadGateway {
+		t.Errorf("no upstream: got %d", w.Code)
+	}
+}
+
+func TestProxyBodyIdle(t *testing.T) {
+	log.New(nil)
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer stalled.Close()
+	defer close(release)
+
+	r := proxyServer([]string{stalled.URL}, ProxyOption{Timeout: time.Second, IdleTimeout: 50 * time.Millisecond})
+	defer r.Close()
+	done := make(chan proxyResult)
+	go func() { done <- proxyGet(r, "/v1/api/") }()
+	select {
+	case w := <-done:
+		// 中止转发后连接被断开，客户端读到不完整的响应
+		if w.Code != 200 || w.Err == nil || w.Body != "partial" {
+			t.Errorf("got %d %q: %v", w.Code, w.Body, w.Err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("proxy hung on a stalled response body")
+	}
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/errno"
+)
+
+// PanicHook panic 发生后调用，可用于告警
+type PanicHook func(c *gin.Context, err interface{}, stack []byte)
+
+var panicHooks []PanicHook
+
+func OnPanic(hook PanicHook) {
+	panicHooks = append(panicHooks, hook)
+}
+
+// Recovery 捕获 panic，记录堆栈并返回 JSON 格式的 ApiResponse
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				// 转发或流式响应中途失败时由 net/http 断开连接，使客户端感知响应不完整
+				if err == http.ErrAbortHandler {
+					c.Abort()
+					panic(err)
+				}
+				stack := debug.Stack()
+				log.CError("Api panic recovered", map[string]interface{}{
+					"panic":      fmt.Sprint(err),
+					"stacktrace": string(stack),
+					"request_id": RequestId(c),
+					"route":      RoutePath(c),
+					"method":     c.Request.Method,
+				})
+				for _, hook := range panicHooks {
+					runHook(hook, c, err, stack)
+				}
+				if c.Writer.Written() {
+					c.Abort()
+					return
+				}
+				c.AbortWithStatusJSON(500, &ApiResponse{
+					ErrCode: errno.ErrInternal,
+					Message: errno.ErrCode[errno.ErrInternal],
+					Data: gin.H{},
+				})
+			}
+		}()
+		c.Next()
+	}
+}
+
+func runHook(hook PanicHook, c *gin.Context, err interface{}, stack []byte) {
+	defer func() {
+		if e := recover(); e != nil {
+			log.Error(fmt.Sprintf("panic hook error: %v", e))
+		}
+	}()
+	hook(c, err, stack)
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"encoding/xml"
+	"github.com/ugorji/go/codec"
+	"github.com/json-iterator/go"
+	"github.com/golang/protobuf/proto"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jinycoo/jinygo/log"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatXML      = "xml"
+	FormatMsgPack  = "msgpack"
+	FormatProtobuf = "protobuf"
+
+	HeaderMsgCode = "X-Msg-Code"
+	HeaderMessage = "X-Message"
+)
+
+// DefaultFormat 请求未指定 Accept 或无法匹配时的响应格式，可在 app.yml 中通过 format 配置
+var DefaultFormat = FormatJSON
+
+var formatMimes = map[string]string{
+	FormatJSON:     binding.MIMEJSON,
+	FormatXML:      binding.MIMEXML,
+	FormatMsgPack:  binding.MIMEMSGPACK,
+	FormatProtobuf: binding.MIMEPROTOBUF,
+}
+
+// Render 按 Accept 请求头渲染响应，支持 JSON、XML、MessagePack
+// 响应数据为 proto.Message 时支持 Protobuf，此时仅输出 Data，错误码及信息通过 X-Msg-Code、X-Message 响应头返回
+func (ic *InContext) Render(status int, obj interface{}) {
+	format := negotiateFormat(ic.Ctx.GetHeader("Accept"), protoData(obj) != nil)
+	body, err := encode(format, obj)
+	if err != nil && format != FormatJSON {
+		log.Warn("响应编码失败：" + err.Error())
+		format = FormatJSON
+		body, err = encode(format, obj)
+	}
+	if err != nil {
+		log.Error("响应编码失败：" + err.Error())
+		ic.Ctx.AbortWithStatus(500)
+		return
+	}
+	code, message := envelope(obj)
+	ic.Ctx.Set(ContextKeyMsgCode, code)
+	if format == FormatProtobuf {
+		ic.Ctx.Header(HeaderMsgCode, strconv.Itoa(code))
+		ic.Ctx.Header(HeaderMessage, message)
+	}
+	contentType := formatMimes[format]
+	if format == FormatJSON || format == FormatXML {
+		contentType += "; charset=utf-8"
+		log.Info(string(body))
+	} else {
+		log.CInfo("response", map[string]interface{}{"format": format, "size": len(body)})
+	}
+	ic.Ctx.Header("Vary", "Accept")
+	ic.Ctx.Data(status, contentType, body)
+}
+
+// Bind 按 Content-Type 解析请求体（JSON、XML、MessagePack、Protobuf 或表单）并校验
+func (ic *InContext) Bind(obj interface{}) error {
+	return ic.Ctx.ShouldBindWith(obj, bindingFor(ic.Ctx.Request.Method, ic.Ctx.ContentType()))
+}
+
+func bindingFor(method, contentType string) binding.Binding {
+	switch {
+	case strings.HasSuffix(contentType, "+json"):
+		return binding.JSON
+	case strings.HasSuffix(contentType, "+xml"):
+		return binding.XML
+	}
+	return binding.Default(method, contentType)
+}
+
+func encode(format string, obj interface{}) ([]byte, error) {
+	switch format {
+	case FormatXML:
+		return xml.Marshal(obj)
+	case FormatMsgPack:
+		var buf []byte
+		err := codec.NewEncoderBytes(&buf, new(codec.MsgpackHandle)).Encode(obj)
+		return buf, err
+	case FormatProtobuf:
+		return proto.Marshal(protoData(obj))
+	default:
+		return jsoniter.Marshal(obj)
+	}
+}
+
+func protoData(obj interface{}) proto.Message {
+	var data interface{}
+	switch res := obj.(type) {
+	case *ApiResponse:
+		data = res.Data
+	case *OldApiResponse:
+		data = res.Data
+	default:
+		data = obj
+	}
+	if m, ok := data.(proto.Message); ok {
+		return m
+	}
+	return nil
+}
+
+func envelope(obj interface{}) (int, string) {
+	switch res := obj.(type) {
+	case *ApiResponse:
+		return res.ErrCode, res.Message
+	case *OldApiResponse:
+		return res.ErrCode, res.Message
+	}
+	return 0, ""
+}
+
+type acceptItem struct {
+	mime string
+	q    float64
+}
+
+// parseAccept 解析 Accept、Accept-Encoding 等请求头，按 q 值降序返回，包含 q=0 的项
+func parseAccept(accept string) []acceptItem {
+	items := make([]acceptItem, 0)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		item := acceptItem{mime: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && kv[0] == "q" {
+				item.q, _ = strconv.ParseFloat(kv[1], 64)
+			}
+		}
+		if item.mime != "" {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+	return items
+}
+
+// negotiateFormat 按 q 值从高到低匹配 Accept 中的媒体类型，支持 application/vnd.xxx+json 形式
+func negotiateFormat(accept string, protoOk bool) string {
+	for _, item := range parseAccept(accept) {
+		if item.q <= 0 {
+			continue
+		}
+		switch {
+		case item.mime == binding.MIMEJSON || strings.HasSuffix(item.mime, "+json"):
+			return FormatJSON
+		case item.mime == binding.MIMEXML || item.mime == binding.MIMEXML2 || strings.HasSuffix(item.mime, "+xml"):
+			return FormatXML
+		case item.mime == binding.MIMEMSGPACK || item.mime == binding.MIMEMSGPACK2:
+			return FormatMsgPack
+		case item.mime == binding.MIMEPROTOBUF && protoOk:
+			return FormatProtobuf
+		case item.mime == "*/*" || item.mime == "application/*":
+			return defaultFormat(protoOk)
+		}
+	}
+	return defaultFormat(protoOk)
+}
+
+func defaultFormat(protoOk bool) string {
+	if DefaultFormat == FormatProtobuf && !protoOk {
+		return FormatJSON
+	}
+	if _, ok := formatMimes[DefaultFormat]; !ok {
+		return FormatJSON
+	}
+	return DefaultFormat
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+)
+
+const (
+	HeaderRequestId = "X-Request-Id"
+
+	ContextKeyRequestId = "jinygo.request_id"
+	ContextKeyRoute     = "jinygo.route"
+)
+
+// requestId 读取或生成请求 ID，写入上下文及响应头
+// 请求上下文同时携带该 ID，供 db 日志及 httpclient 调用上游时使用
+func requestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestId)
+		if id == "" || len(id) > 64 {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(ContextKeyRequestId, id)
+		c.Header(HeaderRequestId, id)
+		c.Request = c.Request.WithContext(log.WithRequestId(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+func routeMarker(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ContextKeyRoute, route)
+		c.Next()
+	}
+}
+
+// RequestId 返回当前请求 ID
+func RequestId(c *gin.Context) string {
+	return c.GetString(ContextKeyRequestId)
+}
+
+// RoutePath 返回当前请求匹配的路由模板，例如 /v1/user/show/:id
+func RoutePath(c *gin.Context) string {
+	if route := c.GetString(ContextKeyRoute); route != "" {
+		return route
+	}
+	return c.Request.URL.Path
+}
+
+func (ic *InContext) RequestId() string {
+	return RequestId(ic.Ctx)
+}
@@ -0,0 +1,211 @@
+package web
+
+import (
+	"time"
+	"bytes"
+	"strings"
+	"net/url"
+	"net/http"
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/cache"
+)
+
+const (
+	responseCachePrefix = "jinygo:rescache:"
+	responseCacheTagPrefix = responseCachePrefix + "tag:"
+
+	HeaderXCache = "X-Cache"
+
+	// ContextKeyNoCache 处理函数 c.Set(ContextKeyNoCache, true) 后本次响应不写入缓存
+	ContextKeyNoCache = "jinygo.no_cache"
+	// ContextKeyMsgCode Render 输出的业务码，非 0 时响应不写入缓存
+	ContextKeyMsgCode = "jinygo.msg_code"
+)
+
+// uncachedHeaders 不随缓存回放的响应头，由各请求自行生成或不可共享
+var uncachedHeaders = map[string]bool{
+	"Set-Cookie":     true,
+	"Date":           true,
+	"Content-Length": true,
+	"Etag":           true,
+	"Last-Modified":  true,
+	HeaderXCache:     true,
+	HeaderRequestId:  true,
+}
+
+// CacheOption 响应缓存配置
+// Query 为参与缓存键计算的查询参数，为空时使用全部查询参数
+// Vary 为参与缓存键计算的请求头
+// Tags/TagFunc 为缓存打标签，写操作后可通过 InvalidateCache 按标签清除
+// 仅缓存状态码 200 且业务码为 0 的响应，处理函数设置的响应头（Set-Cookie 等除外）随缓存回放
+type CacheOption struct {
+	TTL     time.Duration
+	Query   []string
+	Vary    []string
+	Tags    []string
+	TagFunc func(c *gin.Context) []string
+}
+
+type cachedResponse struct {
+	Status       int               `json:"status"`
+	Header       http.Header       `json:"header"`
+	Body         []byte            `json:"body"`
+	ETag         string            `json:"etag"`
+	LastModified int64             `json:"last_modified"`
+}
+
+type cacheWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// ResponseCache 将 GET 请求的响应缓存在 cache.RCache 中，并处理 ETag/Last-Modified 条件请求
+func ResponseCache(opt CacheOption) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet || cache.RCache == nil || opt.TTL <= 0 {
+			c.Next()
+			return
+		}
+		key := responseCachePrefix + opt.cacheKey(c)
+		if buf, err := cache.RCache.Get(key).Bytes(); err == nil {
+			var res cachedResponse
+			if err := jsoniter.Unmarshal(buf, &res); err == nil {
+				c.Header(HeaderXCache, "HIT")
+				res.write(c)
+				c.Abort()
+				return
+			}
+		}
+
+		writer := &cacheWriter{ResponseWriter: c.Writer, body: new(bytes.Buffer)}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		res := &cachedResponse{
+			Status:       writer.Status(),
+			Header:       make(http.Header),
+			Body:         writer.body.Bytes(),
+			LastModified: time.Now().Unix(),
+		}
+		if res.Status != http.StatusOK || c.GetInt(ContextKeyMsgCode) != 0 || c.GetBool(ContextKeyNoCache) {
+			c.Writer.WriteHeader(res.Status)
+			c.Writer.Write(res.Body)
+			return
+		}
+		sum := sha1.Sum(res.Body)
+		res.ETag = `"` + hex.EncodeToString(sum[:]) + `"`
+		for k, v := range c.Writer.Header() {
+			if !uncachedHeaders[k] {
+				res.Header[k] = v
+			}
+		}
+		c.Header(HeaderXCache, "MISS")
+		res.write(c)
+
+		if buf, err := jsoniter.Marshal(res); err == nil {
+			pipe := cache.RCache.TxPipeline()
+			pipe.Set(key, buf, opt.TTL)
+			for _, tag := range opt.tags(c) {
+				pipe.SAdd(responseCacheTagPrefix + tag, key)
+				pipe.Expire(responseCacheTagPrefix + tag, opt.TTL)
+			}
+			if _, err := pipe.Exec(); err != nil {
+				log.Warn("响应缓存写入失败：" + err.Error())
+			}
+		}
+	}
+}
+
+// InvalidateCache 按标签清除响应缓存
+func InvalidateCache(tags ...string) error {
+	if cache.RCache == nil {
+		return nil
+	}
+	for _, tag := range tags {
+		tagKey := responseCacheTagPrefix + tag
+		keys, err := cache.RCache.SMembers(tagKey).Result()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, tagKey)
+		if err := cache.RCache.Del(keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (opt CacheOption) cacheKey(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	if len(opt.Query) > 0 {
+		selected := url.Values{}
+		for _, q := range opt.Query {
+			if v, ok := query[q]; ok {
+				selected[q] = v
+			}
+		}
+		query = selected
+	}
+	// Render 按 Accept 协商响应格式，格式始终参与缓存键
+	raw := c.Request.URL.Path + "?" + query.Encode() + "\nformat:" + negotiateFormat(c.GetHeader("Accept"), true)
+	for _, h := range opt.Vary {
+		raw += "\n" + h + ":" + c.GetHeader(h)
+	}
+	sum := sha1.Sum([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func (opt CacheOption) tags(c *gin.Context) []string {
+	tags := append([]string{}, opt.Tags...)
+	if opt.TagFunc != nil {
+		tags = append(tags, opt.TagFunc(c)...)
+	}
+	return tags
+}
+
+func (res *cachedResponse) write(c *gin.Context) {
+	lastModified := time.Unix(res.LastModified, 0).UTC()
+	c.Header("ETag", res.ETag)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	if res.notModified(c.Request, lastModified) {
+		c.Writer.WriteHeader(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	for k, v := range res.Header {
+		c.Writer.Header()[k] = v
+	}
+	c.Writer.WriteHeader(res.Status)
+	c.Writer.Write(res.Body)
+}
+
+func (res *cachedResponse) notModified(req *http.Request, lastModified time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == res.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	if since := req.Header.Get("If-Modified-Since"); since != "" {
+		if t, err := http.ParseTime(since); err == nil {
+			return !lastModified.After(t)
+		}
+	}
+	return false
+}
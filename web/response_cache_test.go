@@ -0,0 +1,98 @@
+package web
+
+import (
+	"time"
+	"strings"
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/alicebob/miniredis"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/cache"
+)
+
+func TestResponseCacheVariesByFormat(t *testing.T) {
+	log.New(nil)
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	saved := cache.RCache
+	cache.RCache = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { cache.RCache = saved }()
+
+	r := gin.New()
+	r.GET("/items", ResponseCache(CacheOption{TTL: time.Minute}), func(c *gin.Context) {
+		(&InContext{Ctx: c}).Render(200, &ApiResponse{Data: gin.H{"id": 1}})
+	})
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/items", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	get("application/xml")
+	w := get("application/json")
+	if w.Header().Get(HeaderXCache) != "MISS" || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("json request served %s %q", w.Header().Get(HeaderXCache), w.Header().Get("Content-Type"))
+	}
+	w = get("application/xml")
+	if w.Header().Get(HeaderXCache) != "HIT" || !strings.Contains(w.Header().Get("Content-Type"), "xml") || w.Header().Get("Vary") != "Accept" {
+		t.Fatalf("xml hit: %s %q vary %q", w.Header().Get(HeaderXCache), w.Header().Get("Content-Type"), w.Header().Get("Vary"))
+	}
+}
+
+func TestResponseCacheHeadersAndErrors(t *testing.T) {
+	log.New(nil)
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+	saved := cache.RCache
+	cache.RCache = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { cache.RCache = saved }()
+
+	r := gin.New()
+	opt := CacheOption{TTL: time.Minute}
+	r.GET("/items", ResponseCache(opt), func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=60")
+		c.Header("Link", `</items?page=2>; rel="next"`)
+		c.SetCookie("sid", "abc", 0, "/", "", false, true)
+		(&InContext{Ctx: c}).Render(200, &ApiResponse{Data: gin.H{"id": 1}})
+	})
+	r.GET("/fail", ResponseCache(opt), func(c *gin.Context) {
+		(&InContext{Ctx: c}).Render(200, &ApiResponse{ErrCode: 40001})
+	})
+	r.GET("/skip", ResponseCache(opt), func(c *gin.Context) {
+		c.Set(ContextKeyNoCache, true)
+		c.String(200, "ok")
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	get("/items")
+	w := get("/items")
+	if w.Header().Get(HeaderXCache) != "HIT" {
+		t.Fatalf("/items second request: %s", w.Header().Get(HeaderXCache))
+	}
+	if w.Header().Get("Cache-Control") != "public, max-age=60" || !strings.Contains(w.Header().Get("Link"), "page=2") {
+		t.Errorf("handler headers not replayed: %v", w.Header())
+	}
+	if w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("cookie replayed from cache: %s", w.Header().Get("Set-Cookie"))
+	}
+	for _, path := range []string{"/fail", "/skip"} {
+		get(path)
+		if w := get(path); w.Header().Get(HeaderXCache) != "" {
+			t.Errorf("%s cached: %s", path, w.Header().Get(HeaderXCache))
+		}
+	}
+}
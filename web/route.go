@@ -24,11 +24,16 @@ type (
 	RuGroup struct {
 		Name string
 		Child []*Route
+		Handlers []gin.HandlerFunc
 	}
 	Route struct {
 		Name string
 		Method string
 		Controller gin.HandlerFunc
+		Handlers []gin.HandlerFunc
+
+		// stream 为 true 的长连接路由（SSE、WebSocket、反向代理）不使用全局超时
+		stream bool
 	}
 )
 
@@ -56,27 +61,46 @@ func (g *RuGroup) Resource(resourceName string, controllers ...gin.HandlerFunc)
 	}
 }
 
-func (g *RuGroup) Get(relativePath string, controller gin.HandlerFunc) {
-	g.add(constants.MethodGet, relativePath, controller)
+// Use 为整个路由组添加中间件
+func (g *RuGroup) Use(middleware ...gin.HandlerFunc) {
+	g.Handlers = append(g.Handlers, middleware...)
+	Routes[g.Name] = g
+}
+
+func (g *RuGroup) Get(relativePath string, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	g.add(constants.MethodGet, relativePath, controller, middleware...)
 }
 
-func (g *RuGroup) Post(relativePath string, controller gin.HandlerFunc) {
-	g.add(constants.MethodPost, relativePath, controller)
+func (g *RuGroup) Post(relativePath string, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	g.add(constants.MethodPost, relativePath, controller, middleware...)
 }
 
-func (g *RuGroup) Put(relativePath string, controller gin.HandlerFunc) {
-	g.add(constants.MethodPut, relativePath, controller)
+func (g *RuGroup) Put(relativePath string, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	g.add(constants.MethodPut, relativePath, controller, middleware...)
 }
-func (g *RuGroup) Del(relativePath string, controller gin.HandlerFunc) {
-	g.add(constants.MethodDelete, relativePath, controller)
+func (g *RuGroup) Del(relativePath string, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	g.add(constants.MethodDelete, relativePath, controller, middleware...)
 }
 
-func (g *RuGroup) add(method, relativePath string, controller gin.HandlerFunc) {
+func (g *RuGroup) add(method, relativePath string, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) *Route {
 	r := &Route {
 		Name: constants.Separator + strings.Trim(relativePath, constants.Separator),
 		Method: method,
 		Controller: controller,
+		Handlers: middleware,
 	}
 	g.Child = append(g.Child, r)
 	Routes[g.Name] = g
+	return r
+}
+
+// addStream 添加长连接路由
+func (g *RuGroup) addStream(method, relativePath string, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	g.add(method, relativePath, controller, middleware...).stream = true
+}
+
+func (r *Route) handlers() []gin.HandlerFunc {
+	chain := make([]gin.HandlerFunc, 0, len(r.Handlers) + 1)
+	chain = append(chain, r.Handlers...)
+	return append(chain, r.Controller)
 }
\ No newline at end of file
@@ -0,0 +1,215 @@
+package web
+
+import (
+	"time"
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"io/ioutil"
+	"net/http"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/secret"
+	"github.com/jinycoo/jinygo/cache"
+	"github.com/jinycoo/jinygo/errno"
+)
+
+const (
+	HeaderAppKey    = "X-App-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+
+	ContextKeyAppKey = "jinygo.app_key"
+
+	DefaultSignWindow  = 5 * time.Minute
+	DefaultSignMaxBody = 10 << 20
+	nonceKeyPrefix     = "jinygo:nonce:"
+)
+
+var (
+	ErrAppKeyNotFound = errors.New("app key not found")
+	ErrNoNonceStore   = errors.New("nonce store not configured")
+)
+
+type (
+	// SecretStore 根据 app key 查询 secret，db.AppSecrets 提供了基于数据表的实现
+	SecretStore interface {
+		Secret(appKey string) (string, error)
+	}
+
+	// StaticSecrets 基于配置文件的 app key/secret
+	StaticSecrets map[string]string
+
+	// NonceStore 记录已使用的 nonce，nonce 首次使用时返回 true
+	NonceStore interface {
+		Use(key string, ttl time.Duration) (bool, error)
+	}
+
+	// redisNonces 基于 cache.RCache 的 nonce 存储，cache 未初始化时返回 ErrNoNonceStore
+	redisNonces struct{}
+
+	// SignOption 签名校验配置，Window 为允许的时间戳偏差，MaxBody 为参与签名的请求体上限，默认 10MB
+	// Nonces 为空时使用 cache.RCache，nonce 无法校验时拒绝请求
+	SignOption struct {
+		Store   SecretStore
+		Nonces  NonceStore
+		Window  time.Duration
+		MaxBody int64
+	}
+)
+
+func (redisNonces) Use(key string, ttl time.Duration) (bool, error) {
+	if cache.RCache == nil {
+		return false, ErrNoNonceStore
+	}
+	return cache.RCache.SetNX(key, 1, ttl).Result()
+}
+
+func (s StaticSecrets) Secret(appKey string) (string, error) {
+	if secret, ok := s[appKey]; ok {
+		return secret, nil
+	}
+	return "", ErrAppKeyNotFound
+}
+
+// LoadAppSecrets 从 yml 文件读取 app key/secret，格式为 apps: {app_key: secret}
+func LoadAppSecrets(file string) (StaticSecrets, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var cfg struct {
+		Apps StaticSecrets `yaml:"apps"`
+	}
+	if err := secret.Unmarshal(buf, &cfg); err != nil {
+		return nil, err
+	}
+	return cfg.Apps, nil
+}
+
+// VerifySignature 校验 HMAC-SHA256 请求签名
+// 签名串为 METHOD\nPATH\n排序后的查询参数\n请求体 SHA256\n时间戳\nnonce，签名为其 hex 编码的 HMAC
+func VerifySignature(opt SignOption) gin.HandlerFunc {
+	if opt.Store == nil {
+		panic("web: VerifySignature requires a SecretStore")
+	}
+	if opt.Window <= 0 {
+		opt.Window = DefaultSignWindow
+	}
+	if opt.MaxBody <= 0 {
+		opt.MaxBody = DefaultSignMaxBody
+	}
+	if opt.Nonces == nil {
+		opt.Nonces = redisNonces{}
+	}
+	return func(c *gin.Context) {
+		appKey := c.GetHeader(HeaderAppKey)
+		timestamp := c.GetHeader(HeaderTimestamp)
+		nonce := c.GetHeader(HeaderNonce)
+		signature := c.GetHeader(HeaderSignature)
+		if appKey == "" || timestamp == "" || nonce == "" || signature == "" {
+			abortSign(c, errno.ErrSignMissing)
+			return
+		}
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			abortSign(c, errno.ErrSignTimestamp)
+			return
+		}
+		if skew := time.Since(time.Unix(ts, 0)); skew > opt.Window || skew < -opt.Window {
+			abortSign(c, errno.ErrSignTimestamp)
+			return
+		}
+		secret, err := opt.Store.Secret(appKey)
+		if err != nil || secret == "" {
+			abortSign(c, errno.ErrSignAppKey)
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil {
+			body, err = ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, opt.MaxBody))
+			if err != nil {
+				abortSignStatus(c, http.StatusRequestEntityTooLarge, errno.ErrSignTooLarge)
+				return
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		expected := Sign(secret, c.Request, body, timestamp, nonce)
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+			abortSign(c, errno.ErrSignInvalid)
+			return
+		}
+		ok, err := opt.Nonces.Use(nonceKeyPrefix + appKey + ":" + nonce, 2 * opt.Window)
+		if err != nil {
+			log.Error("nonce 校验失败：" + err.Error())
+			abortSignStatus(c, http.StatusServiceUnavailable, errno.ErrSignNonce)
+			return
+		}
+		if !ok {
+			abortSign(c, errno.ErrSignReplay)
+			return
+		}
+		c.Set(ContextKeyAppKey, appKey)
+		c.Next()
+	}
+}
+
+// Sign 计算请求签名
+func Sign(secret string, req *http.Request, body []byte, timestamp, nonce string) string {
+	bodyHash := sha256.Sum256(body)
+	stringToSign := strings.Join([]string{
+		strings.ToUpper(req.Method),
+		req.URL.Path,
+		req.URL.Query().Encode(),
+		hex.EncodeToString(bodyHash[:]),
+		timestamp,
+		nonce,
+	}, "\n")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest 为请求添加签名头，供调用方或测试使用
+func SignRequest(req *http.Request, appKey, secret string) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	nonce := hex.EncodeToString(b)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(HeaderAppKey, appKey)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Sign(secret, req, body, timestamp, nonce))
+	return nil
+}
+
+func abortSign(c *gin.Context, code int) {
+	abortSignStatus(c, http.StatusUnauthorized, code)
+}
+
+func abortSignStatus(c *gin.Context, status, code int) {
+	c.AbortWithStatusJSON(status, &ApiResponse{
+		ErrCode: code,
+		Message: errno.ErrCode[code],
+		Data: gin.H{},
+	})
+}
+
+func (ic *InContext) AppKey() string {
+	return ic.Ctx.GetString(ContextKeyAppKey)
+}
+
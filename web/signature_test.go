@@ -0,0 +1,67 @@
+package web
+
+import (
+	"sync"
+	"time"
+	"errors"
+	"strings"
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+)
+
+type memNonces struct {
+	sync.Mutex
+	used map[string]bool
+	err  error
+}
+
+func (m *memNonces) Use(key string, ttl time.Duration) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.used[key] {
+		return false, nil
+	}
+	m.used[key] = true
+	return true, nil
+}
+
+func TestVerifySignature(t *testing.T) {
+	log.New(nil)
+	nonces := &memNonces{used: make(map[string]bool)}
+	r := gin.New()
+	r.POST("/s", VerifySignature(SignOption{Store: StaticSecrets{"app": "secret"}, Nonces: nonces, MaxBody: 16}), func(c *gin.Context) {
+		c.String(200, "ok")
+	})
+	send := func(body string) int {
+		req := httptest.NewRequest("POST", "/s", strings.NewReader(body))
+		SignRequest(req, "app", "secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	req := httptest.NewRequest("POST", "/s", strings.NewReader("a=1"))
+	SignRequest(req, "app", "secret")
+	replay := req.Header
+	for i, want := range []int{200, 401} {
+		req := httptest.NewRequest("POST", "/s", strings.NewReader("a=1"))
+		req.Header = replay
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("attempt %d: status %d, want %d", i, w.Code, want)
+		}
+	}
+	if code := send(strings.Repeat("x", 32)); code != 413 {
+		t.Fatalf("large body: status %d, want 413", code)
+	}
+	nonces.err = errors.New("redis down")
+	if code := send("a=1"); code != 503 {
+		t.Fatalf("nonce store error: status %d, want 503", code)
+	}
+}
@@ -0,0 +1,197 @@
+package web
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/constants"
+)
+
+const defaultIndexFile = "index.html"
+
+var (
+	Statics     = make([]*StaticConfig, 0)
+	filesystems = make(map[string]http.FileSystem)
+
+	precompressed = []struct {
+		encoding string
+		ext      string
+	}{
+		{"br", ".br"},
+		{"gzip", ".gz"},
+	}
+)
+
+// StaticConfig 静态文件配置，Dir 与 Embed 二选一
+// Embed 为通过 RegisterFS 注册的文件系统名称，Spa 为 true 时未匹配的非 API 路径返回 Index
+type StaticConfig struct {
+	Prefix string `yaml:"prefix"`
+	Dir    string `yaml:"dir"`
+	Embed  string `yaml:"embed"`
+	Index  string `yaml:"index"`
+	MaxAge int    `yaml:"maxAge"`
+	Spa    bool   `yaml:"spa"`
+
+	fs http.FileSystem
+}
+
+// RegisterFS 注册文件系统（例如 http.FS(embed.FS)），供 app.yml 中 static.embed 引用
+func RegisterFS(name string, fs http.FileSystem) {
+	filesystems[name] = fs
+}
+
+// handleNoRoute 依次尝试静态文件、SPA 入口，API 路由组下的路径始终返回 JsonHandle404
+func handleNoRoute(c *gin.Context) {
+	method := c.Request.Method
+	if method == constants.MethodGet || method == constants.MethodHead {
+		reqPath := c.Request.URL.Path
+		for _, s := range Statics {
+			if s.fs == nil || !s.match(reqPath) {
+				continue
+			}
+			if s.serveFile(c, strings.TrimPrefix(reqPath, s.Prefix)) {
+				return
+			}
+			if s.Spa && !isApiPath(reqPath) && s.serveFile(c, s.Index) {
+				return
+			}
+		}
+	}
+	JsonHandle404(c)
+}
+
+func initStatics() {
+	for _, s := range Statics {
+		s.Prefix = constants.Separator + strings.Trim(s.Prefix, constants.Separator)
+		if s.Index == "" {
+			s.Index = defaultIndexFile
+		}
+		switch {
+		case s.Embed != "":
+			if fs, ok := filesystems[s.Embed]; ok {
+				s.fs = fs
+			} else {
+				log.Error(s.Embed + " - Static filesystem does not exist.")
+			}
+		case s.Dir != "":
+			s.fs = http.Dir(s.Dir)
+		default:
+			log.Error(s.Prefix + " - Static dir or embed must be setting")
+		}
+	}
+}
+
+// staticRoot 是否有静态文件挂载在 /
+func staticRoot() bool {
+	for _, s := range Statics {
+		if s.fs != nil && s.Prefix == constants.Separator {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *StaticConfig) match(reqPath string) bool {
+	if s.Prefix == constants.Separator {
+		return true
+	}
+	return reqPath == s.Prefix || strings.HasPrefix(reqPath, s.Prefix + constants.Separator)
+}
+
+func (s *StaticConfig) serveFile(c *gin.Context, name string) bool {
+	name = path.Clean(constants.Separator + name)
+	f, err := s.fs.Open(name)
+	if err != nil {
+		return false
+	}
+	stat, err := f.Stat()
+	if err == nil && stat.IsDir() {
+		f.Close()
+		name = path.Join(name, s.Index)
+		if f, err = s.fs.Open(name); err != nil {
+			return false
+		}
+		stat, err = f.Stat()
+	}
+	if err != nil || stat.IsDir() {
+		f.Close()
+		return false
+	}
+
+	header := c.Writer.Header()
+	if path.Base(name) == s.Index {
+		header.Set("Cache-Control", "no-cache")
+	} else if s.MaxAge > 0 {
+		header.Set("Cache-Control", "public, max-age=" + strconv.Itoa(s.MaxAge))
+	}
+	// 存在预压缩文件时响应随 Accept-Encoding 变化，选择 q 值最高的编码，q 值相同时优先 br
+	accept := parseAccept(c.GetHeader("Accept-Encoding"))
+	var (
+		vary     bool
+		best     float64
+		encoding string
+		cf       http.File
+		cstat    os.FileInfo
+	)
+	for _, p := range precompressed {
+		pf, err := s.fs.Open(name + p.ext)
+		if err != nil {
+			continue
+		}
+		pstat, err := pf.Stat()
+		if err != nil || pstat.IsDir() {
+			pf.Close()
+			continue
+		}
+		vary = true
+		if q := encodingQ(accept, p.encoding); q > best {
+			if cf != nil {
+				cf.Close()
+			}
+			best, encoding, cf, cstat = q, p.encoding, pf, pstat
+		} else {
+			pf.Close()
+		}
+	}
+	if vary {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if cf != nil {
+		f.Close()
+		f, stat = cf, cstat
+		header.Set("Content-Encoding", encoding)
+	}
+	defer f.Close()
+	http.ServeContent(c.Writer, c.Request, name, stat.ModTime(), f)
+	return true
+}
+
+// encodingQ 返回客户端对 encoding 的 q 值，未列出时取 * 的 q 值，均未列出时为 0
+func encodingQ(accept []acceptItem, encoding string) float64 {
+	wildcard := 0.0
+	for _, item := range accept {
+		switch item.mime {
+		case encoding:
+			return item.q
+		case "*":
+			wildcard = item.q
+		}
+	}
+	return wildcard
+}
+
+func isApiPath(reqPath string) bool {
+	for name := range Routes {
+		if name == constants.Separator {
+			continue
+		}
+		if reqPath == name || strings.HasPrefix(reqPath, name + constants.Separator) {
+			return true
+		}
+	}
+	return false
+}
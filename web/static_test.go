@@ -0,0 +1,86 @@
+package web
+
+import (
+	"os"
+	"testing"
+	"io/ioutil"
+	"path/filepath"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+)
+
+func TestStaticRootIndex(t *testing.T) {
+	log.New(nil)
+	dir, err := ioutil.TempDir("", "jinygo-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>spa</html>"), 0644)
+
+	saved := Statics
+	defer func() { Statics = saved }()
+	Statics = []*StaticConfig{{Prefix: "/", Dir: dir, Spa: true}}
+
+	r := Engine(gin.TestMode)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 200 || w.Body.String() != "<html>spa</html>" {
+		t.Fatalf("GET / = %d %q, want static index", w.Code, w.Body.String())
+	}
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	log.New(nil)
+	dir, err := ioutil.TempDir("", "jinygo-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, body := range map[string]string{"app.js": "plain", "app.js.gz": "gzip", "app.js.br": "br", "logo.svg": "svg"} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644)
+	}
+
+	saved := Statics
+	defer func() { Statics = saved }()
+	Statics = []*StaticConfig{{Prefix: "/static", Dir: dir}}
+	r := Engine(gin.TestMode)
+
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", "plain"},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip;q=0", "plain"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"*", "br"},
+		{"*, br;q=0", "gzip"},
+		{"identity", "plain"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/static/app.js", nil)
+		if c.accept != "" {
+			req.Header.Set("Accept-Encoding", c.accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		encoding := w.Header().Get("Content-Encoding")
+		if w.Body.String() != c.want || c.want != "plain" && encoding != c.want || c.want == "plain" && encoding != "" {
+			t.Errorf("%q: got %q encoding %q", c.accept, w.Body.String(), encoding)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%q: vary %q", c.accept, w.Header().Get("Vary"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/static/logo.svg", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "svg" || w.Header().Get("Vary") != "" {
+		t.Errorf("without variants: %q vary %q", w.Body.String(), w.Header().Get("Vary"))
+	}
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"time"
+	"strings"
+	"net/url"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-contrib/sse"
+	"github.com/gorilla/websocket"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/constants"
+)
+
+
+func (g *RuGroup) SSE(relativePath string, handler StreamHandler, middleware ...gin.HandlerFunc) {
+	g.addStream(constants.MethodGet, relativePath, DefaultHub.ServeSSE(handler), middleware...)
+}
+
+func (g *RuGroup) WebSocket(relativePath string, handler StreamHandler, middleware ...gin.HandlerFunc) {
+	g.addStream(constants.MethodGet, relativePath, DefaultHub.ServeWebSocket(handler), middleware...)
+}
+
+// ServeSSE 以 Server-Sent Events 方式推送消息，心跳仅用于保持连接，超过 IdleTimeout 未推送消息时断开
+func (h *Hub) ServeSSE(handler StreamHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn := h.newConn()
+		if handler != nil {
+			if err := handler(c, conn); err != nil {
+				c.AbortWithStatusJSON(http.StatusForbidden, &ApiResponse{ErrCode: http.StatusForbidden, Message: err.Error(), Data: gin.H{}})
+				return
+			}
+		}
+		h.register(conn)
+		defer h.unregister(conn)
+		defer conn.Close()
+
+		header := c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no")
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(h.Heartbeat)
+		defer heartbeat.Stop()
+		idle := time.NewTimer(h.IdleTimeout)
+		defer idle.Stop()
+		for {
+			select {
+			case msg := <-conn.send:
+				data, _ := jsoniter.MarshalToString(msg.Data)
+				if err := sse.Encode(c.Writer, sse.Event{Event: msg.Event, Data: data}); err != nil {
+					return
+				}
+				c.Writer.Flush()
+				if !idle.Stop() {
+					<-idle.C
+				}
+				idle.Reset(h.IdleTimeout)
+			case <-heartbeat.C:
+				if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-idle.C:
+				return
+			case <-conn.done:
+				return
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// ServeWebSocket 升级为 WebSocket 连接，定时发送 ping，超过 IdleTimeout 未收到客户端数据时断开
+func (h *Hub) ServeWebSocket(handler StreamHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn := h.newConn()
+		if handler != nil {
+			if err := handler(c, conn); err != nil {
+				c.AbortWithStatusJSON(http.StatusForbidden, &ApiResponse{ErrCode: http.StatusForbidden, Message: err.Error(), Data: gin.H{}})
+				return
+			}
+		}
+		upgrader := websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     h.checkOrigin,
+		}
+		ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Warn("websocket 升级失败：" + err.Error())
+			return
+		}
+		h.register(conn)
+		defer h.unregister(conn)
+		defer ws.Close()
+
+		go h.writeWebSocket(ws, conn)
+		ws.SetReadDeadline(time.Now().Add(h.IdleTimeout))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(h.IdleTimeout))
+		})
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				conn.Close()
+				return
+			}
+			ws.SetReadDeadline(time.Now().Add(h.IdleTimeout))
+			if conn.onMessage != nil {
+				conn.onMessage(conn, data)
+			}
+		}
+	}
+}
+
+// checkOrigin 校验 WebSocket 请求来源，防止跨站 WebSocket 劫持，无 Origin 头的非浏览器客户端不受限制
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allow := range h.AllowOrigins {
+		if allow == "*" || strings.EqualFold(strings.TrimRight(allow, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (h *Hub) writeWebSocket(ws *websocket.Conn, conn *Conn) {
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+	defer ws.Close()
+	for {
+		select {
+		case msg := <-conn.send:
+			ws.SetWriteDeadline(time.Now().Add(h.Heartbeat))
+			if err := ws.WriteJSON(msg); err != nil {
+				conn.Close()
+				return
+			}
+		case <-heartbeat.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.Heartbeat)); err != nil {
+				conn.Close()
+				return
+			}
+		case <-conn.done:
+			ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			return
+		}
+	}
+}
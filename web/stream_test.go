@@ -0,0 +1,63 @@
+package web
+
+import (
+	"time"
+	"strings"
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHubCheckOrigin(t *testing.T) {
+	cases := []struct {
+		allow  []string
+		origin string
+		want   bool
+	}{
+		{nil, "", true},
+		{nil, "http://api.example.com", true},
+		{nil, "http://evil.com", false},
+		{[]string{"https://app.example.com/"}, "https://app.example.com", true},
+		{[]string{"https://app.example.com"}, "http://app.example.com", false},
+		{[]string{"*"}, "http://evil.com", true},
+	}
+	for _, c := range cases {
+		h := NewHub()
+		h.AllowOrigins = c.allow
+		r := httptest.NewRequest("GET", "http://api.example.com/ws", nil)
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if got := h.checkOrigin(r); got != c.want {
+			t.Errorf("allow %v origin %q: got %v, want %v", c.allow, c.origin, got, c.want)
+		}
+	}
+}
+
+func TestServeSSEIdle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHub()
+	h.Heartbeat = 5 * time.Millisecond
+	h.IdleTimeout = 50 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/events", nil)
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		h.ServeSSE(nil)(c)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle SSE connection kept open by heartbeats")
+	}
+	if d := time.Since(start); d < h.IdleTimeout {
+		t.Errorf("closed after %v, before idle timeout", d)
+	}
+	if !strings.Contains(w.Body.String(), ": ping") {
+		t.Error("no heartbeat written")
+	}
+}
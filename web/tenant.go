@@ -0,0 +1,168 @@
+package web
+
+import (
+	"net"
+	"time"
+	"strings"
+	"net/http"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/db"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/errno"
+)
+
+const (
+	HeaderTenant = "X-Tenant-Id"
+
+	ContextKeyTenant = "jinygo.tenant"
+)
+
+type (
+	// TenantResolver 从请求中解析租户 ID，无法解析时返回空字符串
+	TenantResolver func(c *gin.Context) string
+
+	// TenantOption Resolvers 按顺序解析，首个非空结果为租户 ID，必须设置，Optional 为 true 时允许无租户的请求
+	TenantOption struct {
+		Resolvers []TenantResolver
+		Optional  bool
+	}
+)
+
+// TenantFromHeader 从请求头解析租户，name 为空时使用 X-Tenant-Id
+// 请求头可由客户端任意设置，仅用于由可信网关设置该请求头并剔除客户端传入值的部署
+func TenantFromHeader(name string) TenantResolver {
+	if name == "" {
+		name = HeaderTenant
+	}
+	return func(c *gin.Context) string {
+		return strings.TrimSpace(c.GetHeader(name))
+	}
+}
+
+// TenantFromSubdomain 从子域名解析租户，例如 domain 为 example.com 时 acme.example.com 的租户为 acme
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(c *gin.Context) string {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return ""
+		}
+		sub := strings.TrimSuffix(host, suffix)
+		if strings.Contains(sub, ".") {
+			return ""
+		}
+		return sub
+	}
+}
+
+// TenantFromClaim 从 Authorization: Bearer 的 JWT 声明解析租户，仅接受 key 签名的 HS256 令牌，过期令牌视为无租户
+func TenantFromClaim(claim string, key []byte) TenantResolver {
+	return func(c *gin.Context) string {
+		auth := c.GetHeader("Authorization")
+		if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+			return ""
+		}
+		claims, ok := verifyHS256(strings.TrimSpace(auth[7:]), key)
+		if !ok {
+			return ""
+		}
+		if v, ok := claims[claim].(string); ok {
+			return v
+		}
+		return ""
+	}
+}
+
+func verifyHS256(token string, key []byte) (map[string]interface{}, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || len(key) == 0 {
+		return nil, false
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || jsoniter.Unmarshal(buf, &header) != nil || header.Alg != "HS256" {
+		return nil, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, false
+	}
+	var claims map[string]interface{}
+	buf, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || jsoniter.Unmarshal(buf, &claims) != nil {
+		return nil, false
+	}
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() >= int64(exp) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// Tenant 解析当前请求的租户并写入请求上下文，handler 通过 db.Tenant(ic.Context()) 使用租户数据组
+// 配置了 db 多租户时同时校验租户是否存在，在首次访问时创建其连接池，并在请求期间持有该连接池
+func Tenant(opt TenantOption) gin.HandlerFunc {
+	if len(opt.Resolvers) == 0 {
+		panic("web: tenant resolvers must be setting")
+	}
+	return func(c *gin.Context) {
+		var tenant string
+		for _, resolve := range opt.Resolvers {
+			if tenant = resolve(c); tenant != "" {
+				break
+			}
+		}
+		if tenant == "" {
+			if !opt.Optional {
+				abortTenant(c, http.StatusBadRequest, errno.ErrTenantMissing)
+			}
+			return
+		}
+		ctx := db.WithTenant(c.Request.Context(), tenant)
+		if db.Tenants != nil {
+			var (
+				release func()
+				err     error
+			)
+			if ctx, release, err = db.AcquireTenant(c.Request.Context(), tenant); err == db.ErrTenantNotFound {
+				abortTenant(c, http.StatusNotFound, errno.ErrTenantNotFound)
+				return
+			} else if err != nil {
+				log.Error(tenant + " - Tenant database error: " + err.Error())
+				abortTenant(c, http.StatusServiceUnavailable, errno.ErrTenantUnavailable)
+				return
+			}
+			defer release()
+		}
+		c.Set(ContextKeyTenant, tenant)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// Tenant 返回当前请求的租户 ID
+func (ic *InContext) Tenant() string {
+	return ic.Ctx.GetString(ContextKeyTenant)
+}
+
+func abortTenant(c *gin.Context, status, code int) {
+	c.AbortWithStatusJSON(status, &ApiResponse{
+		ErrCode: code,
+		Message: errno.ErrCode[code],
+		Data: gin.H{},
+	})
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/db"
+)
+
+func TestTenantRequiresResolvers(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Tenant without resolvers should panic")
+		}
+	}()
+	Tenant(TenantOption{})
+}
+
+func TestTenantResolve(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Tenant(TenantOption{Resolvers: []TenantResolver{TenantFromSubdomain("example.com")}}))
+	r.GET("/", func(c *gin.Context) {
+		c.String(200, db.TenantId(c.Request.Context()))
+	})
+	cases := []struct {
+		host   string
+		header string
+		status int
+		body   string
+	}{
+		{"acme.example.com", "", 200, "acme"},
+		{"acme.example.com:8080", "beta", 200, "acme"},
+		{"example.com", "beta", 400, ""},
+		{"a.b.example.com", "", 400, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = c.host
+		if c.header != "" {
+			req.Header.Set(HeaderTenant, c.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.status || c.status == 200 && w.Body.String() != c.body {
+			t.Errorf("%s: got %d %s", c.host, w.Code, w.Body.String())
+		}
+	}
+}
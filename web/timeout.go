@@ -0,0 +1,34 @@
+package web
+
+import (
+	"time"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/errno"
+)
+
+// DefaultTimeout 全局请求超时时间，为 0 时不限制，可在 app.yml 中通过 timeout 配置，SSE、WebSocket 及反向代理路由不受限制
+var DefaultTimeout time.Duration
+
+// Timeout 为请求上下文设置超时时间，可用于路由组或单个路由，嵌套时以较早的截止时间为准
+// 处理函数应通过 InContext.Context() 将上下文传递给 db、cache、mqueue 调用
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+			c.AbortWithStatusJSON(504, &ApiResponse{
+				ErrCode: errno.ErrTimeout,
+				Message: errno.ErrCode[errno.ErrTimeout],
+				Data: gin.H{},
+			})
+		}
+	}
+}
+
+// Context 返回携带请求截止时间的上下文，客户端断开或超时后被取消
+func (ic *InContext) Context() context.Context {
+	return ic.Ctx.Request.Context()
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"time"
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+)
+
+func TestTimeoutSkipsStreamRoutes(t *testing.T) {
+	log.New(nil)
+	savedRoutes, savedTimeout := Routes, DefaultTimeout
+	defer func() { Routes, DefaultTimeout = savedRoutes, savedTimeout }()
+	Routes = make(map[string]*RuGroup)
+	DefaultTimeout = time.Minute
+
+	deadline := func(c *gin.Context) {
+		_, ok := c.Request.Context().Deadline()
+		c.JSON(200, gin.H{"deadline": ok})
+	}
+	g := &RuGroup{Name: "/t"}
+	g.Get("api", deadline)
+	g.addStream("GET", "stream", deadline)
+
+	r := Engine(gin.TestMode)
+	for path, want := range map[string]string{"/t/api": `{"deadline":true}`, "/t/stream": `{"deadline":false}`} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != want {
+			t.Errorf("%s = %s, want %s", path, w.Body.String(), want)
+		}
+	}
+}
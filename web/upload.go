@@ -0,0 +1,196 @@
+package web
+
+import (
+	"io"
+	"time"
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+	"net/http"
+	"crypto/rand"
+	"encoding/hex"
+	"path/filepath"
+	"github.com/jinycoo/jinygo/storage"
+)
+
+const (
+	DefaultUploadMaxSize = 10 << 20
+	sniffLen = 512
+)
+
+var (
+	ErrUploadTooLarge = errors.New("upload file too large")
+	ErrUploadType     = errors.New("upload file type not allowed")
+	ErrNoStorage      = errors.New("storage backend not configured")
+
+	unsafeFilename = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+	// mimeExts http.DetectContentType 可识别类型的扩展名，其余类型使用 .bin
+	mimeExts = map[string]string{
+		"image/jpeg":                    ".jpg",
+		"image/png":                     ".png",
+		"image/gif":                     ".gif",
+		"image/webp":                    ".webp",
+		"image/bmp":                     ".bmp",
+		"image/x-icon":                  ".ico",
+		"application/pdf":               ".pdf",
+		"application/zip":               ".zip",
+		"application/x-gzip":            ".gz",
+		"application/x-rar-compressed":  ".rar",
+		"application/ogg":               ".ogg",
+		"application/wasm":              ".wasm",
+		"application/postscript":        ".ps",
+		"application/vnd.ms-fontobject": ".eot",
+		"audio/mpeg":                    ".mp3",
+		"audio/wave":                    ".wav",
+		"audio/aiff":                    ".aiff",
+		"audio/basic":                   ".au",
+		"audio/midi":                    ".mid",
+		"video/mp4":                     ".mp4",
+		"video/webm":                    ".webm",
+		"video/avi":                     ".avi",
+		"font/ttf":                      ".ttf",
+		"font/otf":                      ".otf",
+		"font/woff":                     ".woff",
+		"font/woff2":                    ".woff2",
+		"text/plain":                    ".txt",
+		"text/html":                     ".html",
+		"text/xml":                      ".xml",
+	}
+)
+
+// UploadOption 文件上传配置
+// AllowTypes 为允许的 MIME 类型，支持 image/* 形式，类型由文件内容嗅探得出而非客户端声明
+// Storage 为空时使用 storage.Default，Dir 为存储键前缀
+type UploadOption struct {
+	MaxSize    int64
+	AllowTypes []string
+	Storage    storage.Storage
+	Dir        string
+}
+
+// limitedBody 记录请求体是否超出 http.MaxBytesReader 的限制
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// ReceiveFile 接收 multipart 表单中的文件并写入存储后端，返回存储对象信息
+func (ic *InContext) ReceiveFile(field string, opt UploadOption) (*storage.Object, error) {
+	if opt.MaxSize <= 0 {
+		opt.MaxSize = DefaultUploadMaxSize
+	}
+	store := opt.Storage
+	if store == nil {
+		store = storage.Default
+	}
+	if store == nil {
+		return nil, ErrNoStorage
+	}
+	req := ic.Ctx.Request
+	var body *limitedBody
+	if req.MultipartForm == nil {
+		limit := opt.MaxSize + (1 << 20)
+		body = &limitedBody{ReadCloser: http.MaxBytesReader(ic.Ctx.Writer, req.Body, limit), limit: limit}
+		req.Body = body
+	}
+	header, err := ic.Ctx.FormFile(field)
+	if err != nil {
+		if body != nil && body.exceeded {
+			return nil, ErrUploadTooLarge
+		}
+		return nil, err
+	}
+	if header.Size > opt.MaxSize {
+		return nil, ErrUploadTooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !allowedType(contentType, opt.AllowTypes) {
+		return nil, ErrUploadType
+	}
+	// 扩展名由嗅探出的类型决定，避免伪装为图片的 HTML 等文件以客户端声明的扩展名存储
+	ext, ok := mimeExts[strings.TrimSpace(strings.Split(contentType, ";")[0])]
+	if !ok {
+		ext = ".bin"
+	}
+
+	name := SanitizeFilename(header.Filename)
+	key := strings.Trim(opt.Dir, "/")
+	if key != "" {
+		key += "/"
+	}
+	key += time.Now().Format("2006/01/02/") + randomName() + ext
+	obj, err := store.Put(key, io.MultiReader(bytes.NewReader(head), file), header.Size, contentType)
+	if err != nil {
+		return nil, err
+	}
+	obj.Name = name
+	return obj, nil
+}
+
+// SanitizeFilename 去除路径及不安全字符，仅保留字母、数字、点、下划线和中划线
+func SanitizeFilename(name string) string {
+	name = filepath.Base(strings.Replace(name, "\\", "/", -1))
+	name = unsafeFilename.ReplaceAllString(name, "_")
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = "file"
+	}
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		// 按字符边界截断，避免截断多字节字符
+		end := 255 - len(ext)
+		for end > 0 && !utf8.RuneStart(name[end]) {
+			end--
+		}
+		name = name[:end] + ext
+	}
+	return name
+}
+
+func allowedType(contentType string, allowTypes []string) bool {
+	if len(allowTypes) == 0 {
+		return true
+	}
+	mime := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	for _, t := range allowTypes {
+		if t == mime || strings.HasSuffix(t, "/*") && strings.HasPrefix(mime, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomName() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"io"
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/storage"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := []struct {
+		name, want string
+	}{
+		{"../../etc/passwd", "passwd"},
+		{"C:\\\\Users\\\\a b.txt", "a_b.txt"},
+		{"...hidden", "hidden"},
+		{"", "file"},
+		{"报告 2020.pdf", "报告_2020.pdf"},
+	}
+	for _, c := range cases {
+		if got := SanitizeFilename(c.name); got != c.want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSanitizeFilenameTruncateRune(t *testing.T) {
+	for pad := 0; pad < 3; pad++ {
+		name := strings.Repeat("a", pad) + strings.Repeat("文", 100) + ".txt"
+		got := SanitizeFilename(name)
+		if len(got) > 255 || !utf8.ValidString(got) || !strings.HasSuffix(got, ".txt") {
+			t.Errorf("pad %d: invalid result %q (%d bytes)", pad, got, len(got))
+		}
+	}
+}
+
+type memStorage struct {
+	keys []string
+}
+
+func (m *memStorage) Put(key string, r io.Reader, size int64, contentType string) (*storage.Object, error) {
+	ioutil.ReadAll(r)
+	m.keys = append(m.keys, key)
+	return &storage.Object{Key: key, Size: size, ContentType: contentType}, nil
+}
+
+func (m *memStorage) Delete(key string) error { return nil }
+
+func TestReceiveFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	html := []byte("<html><script>alert(1)</script></html>")
+	cases := []struct {
+		filename string
+		content  []byte
+		maxSize  int64
+		ext      string
+		err      error
+	}{
+		{"avatar.png", png, 0, ".png", nil},
+		{"evil.html", png, 0, ".png", nil},
+		{"avatar.png", html, 0, ".html", nil},
+		{"data.png", []byte{0x00, 0x01, 0x02, 0xff}, 0, ".bin", nil},
+		{"big.png", bytes.Repeat(png, 40000), 1024, "", ErrUploadTooLarge},
+	}
+	for _, c := range cases {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", c.filename)
+		fw.Write(c.content)
+		mw.Close()
+
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest("POST", "/upload", &body)
+		ctx.Request.Header.Set("Content-Type", mw.FormDataContentType())
+		store := &memStorage{}
+		obj, err := (&InContext{Ctx: ctx}).ReceiveFile("file", UploadOption{Storage: store, MaxSize: c.maxSize, Dir: "u"})
+		if err != c.err {
+			t.Errorf("%s: err = %v, want %v", c.filename, err, c.err)
+			continue
+		}
+		if err == nil && (!strings.HasPrefix(obj.Key, "u/") || !strings.HasSuffix(obj.Key, c.ext)) {
+			t.Errorf("%s: key = %s, want suffix %s", c.filename, obj.Key, c.ext)
+		}
+	}
+}
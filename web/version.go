@@ -0,0 +1,286 @@
+package web
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"regexp"
+	"strconv"
+	"strings"
+	"net/http"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+	"github.com/jinycoo/jinygo/constants"
+)
+
+const (
+	DefaultVersionHeader = "X-Api-Version"
+	ContextKeyApiVersion = "jinygo.api_version"
+)
+
+type (
+	// VersionOption 接口版本配置
+	// 版本依次由路径前缀（/v2/...）、Header 请求头、Accept 中的 MediaType（application/vnd.jiny.v2+json）
+	// 或 version 参数（application/json; version=2）确定，均未指定时使用 Default
+	VersionOption struct {
+		Default    int
+		Latest     int
+		Header     string
+		MediaType  string
+		Deprecated map[int]*Deprecation
+		// LogEvery 已废弃版本每被调用多少次记录一次日志，默认 100
+		LogEvery   int64
+	}
+
+	// Deprecation 废弃版本信息，输出 Deprecation、Sunset 及 Link 响应头
+	Deprecation struct {
+		Since  time.Time
+		Sunset time.Time
+		Link   string
+	}
+
+	// VersionRange 版本范围，Max 为 0 时表示至 Latest
+	VersionRange struct {
+		Min int
+		Max int
+	}
+
+	VersionedGroup struct {
+		group    *RuGroup
+		option   VersionOption
+		mediaReg *regexp.Regexp
+		routes   map[string]*versionedRoute
+	}
+
+	versionedRoute struct {
+		route   *Route
+		entries []*versionEntry
+	}
+
+	versionEntry struct {
+		versions   VersionRange
+		controller gin.HandlerFunc
+		middleware []gin.HandlerFunc
+	}
+)
+
+var deprecatedCalls = struct {
+	sync.Mutex
+	counts map[string]int64
+}{counts: make(map[string]int64)}
+
+func Since(v int) VersionRange {
+	return VersionRange{Min: v}
+}
+
+func Between(min, max int) VersionRange {
+	return VersionRange{Min: min, Max: max}
+}
+
+func Only(v int) VersionRange {
+	return VersionRange{Min: v, Max: v}
+}
+
+func (vr VersionRange) contains(v int, latest int) bool {
+	return v >= vr.Min && v <= vr.max(latest)
+}
+
+func (vr VersionRange) overlaps(other VersionRange, latest int) bool {
+	return vr.Min <= other.max(latest) && other.Min <= vr.max(latest)
+}
+
+func (vr VersionRange) max(latest int) int {
+	if vr.Max == 0 {
+		return latest
+	}
+	return vr.Max
+}
+
+// Versioned 在路由组上启用版本控制
+func Versioned(group *RuGroup, opt VersionOption) *VersionedGroup {
+	if opt.Default == 0 {
+		opt.Default = 1
+	}
+	if opt.Latest < opt.Default {
+		opt.Latest = opt.Default
+	}
+	if opt.Header == "" {
+		opt.Header = DefaultVersionHeader
+	}
+	if opt.LogEvery <= 0 {
+		opt.LogEvery = 100
+	}
+	vg := &VersionedGroup{
+		group:  group,
+		option: opt,
+		routes: make(map[string]*versionedRoute),
+	}
+	if opt.MediaType != "" {
+		vg.mediaReg = regexp.MustCompile(`^` + regexp.QuoteMeta(opt.MediaType) + `\.v(\d+)`)
+	}
+	return vg
+}
+
+func (vg *VersionedGroup) Get(relativePath string, versions VersionRange, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	vg.add(constants.MethodGet, relativePath, versions, controller, middleware...)
+}
+
+func (vg *VersionedGroup) Post(relativePath string, versions VersionRange, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	vg.add(constants.MethodPost, relativePath, versions, controller, middleware...)
+}
+
+func (vg *VersionedGroup) Put(relativePath string, versions VersionRange, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	vg.add(constants.MethodPut, relativePath, versions, controller, middleware...)
+}
+
+func (vg *VersionedGroup) Del(relativePath string, versions VersionRange, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	vg.add(constants.MethodDelete, relativePath, versions, controller, middleware...)
+}
+
+// add 同一方法及路径的版本范围不可重叠，重叠时 panic
+func (vg *VersionedGroup) add(method, relativePath string, versions VersionRange, controller gin.HandlerFunc, middleware ...gin.HandlerFunc) {
+	relativePath = strings.Trim(relativePath, constants.Separator)
+	key := method + " " + relativePath
+	vr, ok := vg.routes[key]
+	if ok {
+		for _, e := range vr.entries {
+			if e.versions.overlaps(versions, vg.option.Latest) {
+				panic(fmt.Sprintf("web: versioned route %s %s/%s has overlapping version ranges", method, vg.group.Name, relativePath))
+			}
+		}
+	}
+	for v := versions.Min; v <= vg.option.Latest; v++ {
+		if !versions.contains(v, vg.option.Latest) {
+			continue
+		}
+		handlers := append([]gin.HandlerFunc{vg.useVersion(v)}, middleware...)
+		vg.group.add(method, fmt.Sprintf("v%d/%s", v, relativePath), controller, handlers...)
+	}
+
+	if !ok {
+		vg.group.add(method, relativePath, nil)
+		vr = &versionedRoute{route: vg.group.Child[len(vg.group.Child)-1]}
+		vg.routes[key] = vr
+	}
+	vr.entries = append(vr.entries, &versionEntry{versions: versions, controller: controller, middleware: middleware})
+
+	// 未带版本前缀的路由：先解析版本，再执行所选版本的中间件及处理函数，版本范围不重叠，仅所选版本的中间件生效
+	handlers := []gin.HandlerFunc{vg.negotiate()}
+	for _, e := range vr.entries {
+		for _, m := range e.middleware {
+			handlers = append(handlers, vg.when(e.versions, m))
+		}
+	}
+	vr.route.Handlers = handlers
+	vr.route.Controller = vg.dispatch(vr)
+}
+
+func (vg *VersionedGroup) useVersion(v int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		vg.setVersion(c, v)
+	}
+}
+
+func (vg *VersionedGroup) negotiate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		vg.setVersion(c, vg.requestVersion(c.Request))
+	}
+}
+
+func (vg *VersionedGroup) when(versions VersionRange, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if versions.contains(ApiVersion(c), vg.option.Latest) {
+			handler(c)
+		}
+	}
+}
+
+func (vg *VersionedGroup) dispatch(vr *versionedRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v := ApiVersion(c)
+		for _, e := range vr.entries {
+			if e.versions.contains(v, vg.option.Latest) {
+				e.controller(c)
+				return
+			}
+		}
+		JsonHandle404(c)
+	}
+}
+
+func (vg *VersionedGroup) requestVersion(req *http.Request) int {
+	if v, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(req.Header.Get(vg.option.Header)), "v")); err == nil && v > 0 {
+		return v
+	}
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		accept = strings.TrimSpace(accept)
+		if vg.mediaReg != nil {
+			if m := vg.mediaReg.FindStringSubmatch(accept); m != nil {
+				v, _ := strconv.Atoi(m[1])
+				return v
+			}
+		}
+		for _, param := range strings.Split(accept, ";")[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && kv[0] == "version" {
+				if v, err := strconv.Atoi(kv[1]); err == nil && v > 0 {
+					return v
+				}
+			}
+		}
+	}
+	return vg.option.Default
+}
+
+func (vg *VersionedGroup) setVersion(c *gin.Context, v int) {
+	c.Set(ContextKeyApiVersion, v)
+	d, ok := vg.option.Deprecated[v]
+	if !ok {
+		return
+	}
+	if d.Since.IsZero() {
+		c.Header("Deprecation", "true")
+	} else {
+		c.Header("Deprecation", "@" + strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, d.Link))
+	}
+
+	key := fmt.Sprintf("v%d %s %s", v, c.Request.Method, RoutePath(c))
+	deprecatedCalls.Lock()
+	deprecatedCalls.counts[key]++
+	count := deprecatedCalls.counts[key]
+	deprecatedCalls.Unlock()
+	if count == 1 || count%vg.option.LogEvery == 0 {
+		log.CWarn("Deprecated api version called", map[string]interface{}{
+			"version": v,
+			"route":   RoutePath(c),
+			"method":  c.Request.Method,
+			"count":   count,
+		})
+	}
+}
+
+// DeprecatedCalls 返回各废弃版本路由的调用次数
+func DeprecatedCalls() map[string]int64 {
+	deprecatedCalls.Lock()
+	defer deprecatedCalls.Unlock()
+	counts := make(map[string]int64, len(deprecatedCalls.counts))
+	for k, v := range deprecatedCalls.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+// ApiVersion 返回当前请求的接口版本，未启用版本控制时为 0
+func ApiVersion(c *gin.Context) int {
+	return c.GetInt(ContextKeyApiVersion)
+}
+
+func (ic *InContext) ApiVersion() int {
+	return ApiVersion(ic.Ctx)
+}
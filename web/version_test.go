@@ -0,0 +1,62 @@
+package web
+
+import (
+	"testing"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/jinycoo/jinygo/log"
+)
+
+func TestVersionedOverlap(t *testing.T) {
+	vg := Versioned(&RuGroup{Name: "/t"}, VersionOption{Latest: 3})
+	vg.Get("user", Only(1), func(c *gin.Context) {})
+	vg.Get("user", Since(2), func(c *gin.Context) {})
+	defer func() {
+		if recover() == nil {
+			t.Error("overlapping versions registered without panic")
+		}
+	}()
+	vg.Get("user", Only(3), func(c *gin.Context) {})
+}
+
+func TestVersionedMiddleware(t *testing.T) {
+	log.New(nil)
+	savedRoutes := Routes
+	defer func() { Routes = savedRoutes }()
+	Routes = make(map[string]*RuGroup)
+
+	g := &RuGroup{Name: "/t"}
+	vg := Versioned(g, VersionOption{Latest: 2})
+	mark := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("mw", c.GetString("mw") + name)
+		}
+	}
+	reply := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.String(200, name + ":" + c.GetString("mw"))
+		}
+	}
+	vg.Get("user", Only(1), reply("v1"), mark("a"))
+	vg.Get("user", Since(2), reply("v2"), mark("b"))
+	r := Engine(gin.TestMode)
+
+	for _, tc := range []struct {
+		path, version, want string
+	}{
+		{"/t/user", "", "v1:a"},
+		{"/t/user", "2", "v2:b"},
+		{"/t/v1/user", "", "v1:a"},
+		{"/t/v2/user", "", "v2:b"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tc.path, nil)
+		if tc.version != "" {
+			req.Header.Set(DefaultVersionHeader, tc.version)
+		}
+		r.ServeHTTP(w, req)
+		if w.Body.String() != tc.want {
+			t.Errorf("%s (version %q) = %s, want %s", tc.path, tc.version, w.Body.String(), tc.want)
+		}
+	}
+}
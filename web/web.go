@@ -2,6 +2,7 @@ package web
 
 import (
 	"time"
+	"path"
 	"strings"
 	"github.com/gin-gonic/gin"
 	"github.com/jinycoo/jinygo/log"
@@ -9,26 +10,40 @@ import (
 )
 
 func Run(runMode, addr string) {
+	r := Engine(runMode)
+	log.Info("Listening and serving HTTP on " + addr)
+	r.Run(addr)
+}
+
+// Engine 根据已注册的路由构建 gin.Engine，不监听端口
+func Engine(runMode string) *gin.Engine {
 	gin.SetMode(runMode)
 	r := gin.New()
-	r.Use(gin.Recovery())
+	r.Use(requestId())
+	// 访问日志在 Recovery 之外，panic 恢复后的请求以最终状态码记录
 	r.Use(incLogger())
-	r.NoRoute(JsonHandle404)
-	if len(Routes) == 0 {
+	r.Use(Recovery())
+	initStatics()
+	r.NoRoute(handleNoRoute)
+	// 未注册根路由且没有静态文件挂载在 / 时返回默认首页，否则由静态文件的 index 处理
+	if _, ok := Routes[constants.Separator]; !ok && !staticRoot() {
 		r.GET(constants.Separator, JsonHandleIndex)
-	} else {
-		if _,ok := Routes[constants.Separator]; !ok {
-			r.GET(constants.Separator, JsonHandleIndex)
-		}
+	}
+	if len(Routes) > 0 {
 		for _,v := range Routes {
 			module := r.Group(v.Name)
 			for _,re := range v.Child {
-				module.Handle(re.Method, re.Name, re.Controller)
+				handlers := []gin.HandlerFunc{routeMarker(path.Join(v.Name, re.Name))}
+				// 全局超时按路由添加，SSE、WebSocket 及反向代理等长连接路由不受限制
+				if DefaultTimeout > 0 && !re.stream {
+					handlers = append(handlers, Timeout(DefaultTimeout))
+				}
+				handlers = append(handlers, v.Handlers...)
+				module.Handle(re.Method, re.Name, append(handlers, re.handlers()...)...)
 			}
 		}
 	}
-	log.Info("Listening and serving HTTP on " + addr)
-	r.Run(addr)
+	return r
 }
 
 func incLogger() gin.HandlerFunc {
@@ -39,6 +54,7 @@ func incLogger() gin.HandlerFunc {
 		req["method"] = c.Request.Method
 		req["query"] = strings.Split(c.Request.URL.RawQuery, "&")
 		req["client_ip"] = c.ClientIP()
+		req["request_id"] = RequestId(c)
 		//body := c.Request.Body
 		//var bodyBytes []byte
 		//if body != nil {
@@ -49,10 +65,16 @@ func incLogger() gin.HandlerFunc {
 		//}
 		//b,_ := jsoniter.Marshal(req)
 		//log.Info(string(b))
+		// 中断连接的请求（http.ErrAbortHandler）经 Recovery 继续 panic，在 defer 中记录
+		defer func() {
+			//var res  = make(map[string]interface{}, 0)
+			req["requested_time"] = time.Now().Unix() - reqStart
+			req["status"] = c.Writer.Status()
+			if upstream := c.GetString(ContextKeyUpstream); upstream != "" {
+				req["upstream"] = upstream
+			}
+			log.CInfo("Api request info", req)
+		}()
 		c.Next()
-		//var res  = make(map[string]interface{}, 0)
-		req["requested_time"] = time.Now().Unix() - reqStart
-		req["status"] = c.Writer.Status()
-		log.CInfo("Api request info", req)
 	}
 }
\ No newline at end of file
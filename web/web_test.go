@@ -0,0 +1,59 @@
+package web
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"io/ioutil"
+	"path/filepath"
+	"net/http/httptest"
+	"github.com/gin-gonic/gin"
+	"github.com/json-iterator/go"
+	"github.com/jinycoo/jinygo/log"
+)
+
+func TestAccessLogRecovered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jinygo-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := log.DevConfig()
+	cfg.Encoding = "json"
+	cfg.OutPuts = []string{log.File}
+	cfg.LogPath = dir
+	log.New(cfg)
+	defer log.New(nil)
+
+	savedRoutes, savedTimeout := Routes, DefaultTimeout
+	defer func() { Routes, DefaultTimeout = savedRoutes, savedTimeout }()
+	Routes = make(map[string]*RuGroup)
+	DefaultTimeout = 0
+	g := &RuGroup{Name: "/t"}
+	g.Get("panic", func(c *gin.Context) { panic("boom") })
+
+	r := Engine(gin.TestMode)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/t/panic", nil))
+	if w.Code != 500 {
+		t.Fatalf("status = %d", w.Code)
+	}
+	log.Sync()
+
+	files, _ := ioutil.ReadDir(dir)
+	var status interface{}
+	for _, f := range files {
+		buf, _ := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		for _, line := range strings.Split(string(buf), "\n") {
+			if !strings.Contains(line, "Api request info") {
+				continue
+			}
+			var entry map[string]interface{}
+			jsoniter.Unmarshal([]byte(line), &entry)
+			status = entry["status"]
+		}
+	}
+	if status != float64(500) {
+		t.Errorf("access log status = %v, want 500", status)
+	}
+}